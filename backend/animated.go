@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ffwatermark/filtergraph"
+)
+
+// 动态水印播放方式
+const (
+	playbackLoop = "loop" // 循环播放直到主画面结束
+	playbackOnce = "once" // 只播放一次, 结束后不再显示
+)
+
+// isAnimatedWatermark 判断水印是否为动图或视频
+func isAnimatedWatermark(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gif", ".apng", ".webm", ".mov", ".mp4", ".mkv":
+		return true
+	case ".png":
+		// APNG 通常仍使用 .png 扩展名
+		return isAPNG(path)
+	}
+	return false
+}
+
+// pngSignature PNG 文件头
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// isAPNG 判断 PNG 文件是否为动画 PNG: APNG 在第一个 IDAT 块之前包含 acTL 块, 无法读取时返回 false
+func isAPNG(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header, pngSignature) {
+		return false
+	}
+
+	// 每个块依次为 4 字节长度、4 字节类型、数据和 4 字节 CRC
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			return false
+		}
+		switch string(chunk[4:]) {
+		case "acTL":
+			return true
+		case "IDAT", "IEND":
+			return false
+		}
+		length := int64(binary.BigEndian.Uint32(chunk[:4]))
+		if _, err := file.Seek(length+4, io.SeekCurrent); err != nil {
+			return false
+		}
+	}
+}
+
+// webmDecoder 返回 WebM 水印使用的解码器: VP8 使用 libvpx, VP9 使用 libvpx-vp9; 无法探测时按 VP9 处理
+func webmDecoder(path string) string {
+	info, err := probeMediaInfo(path)
+	if err != nil || info.Video == nil {
+		slog.Warn("无法探测 WebM 水印编码, 按 VP9 解码", "path", path, "error", err)
+		return "libvpx-vp9"
+	}
+	if info.Video.Codec == "vp8" {
+		return "libvpx"
+	}
+	return "libvpx-vp9"
+}
+
+// validatePlayback 校验动态水印的播放设置
+func validatePlayback(layer WatermarkLayer) error {
+	switch layer.Playback {
+	case "", playbackLoop, playbackOnce:
+	default:
+		return fmt.Errorf("invalid playback: %q", layer.Playback)
+	}
+
+	if layer.Mode == "tile" && isAnimatedWatermark(layer.WatermarkPath) {
+		return fmt.Errorf("tile mode requires a static watermark image")
+	}
+
+	return nil
+}
+
+// watermarkInputArgs 构建水印输入文件之前的输入参数
+func watermarkInputArgs(layer WatermarkLayer) []string {
+	if !isAnimatedWatermark(layer.WatermarkPath) {
+		// 静态图片只有一帧, 随时间变化的透明度效果需要循环输出连续的帧
+		if hasAlphaEffects(layer) {
+			return []string{"-loop", "1"}
+		}
+		return nil
+	}
+
+	// 水印中的音频不参与输出
+	args := []string{"-an"}
+
+	// 循环读取输入, 由 overlay 的 shortest 选项在主画面结束时停止
+	if layer.Playback != playbackOnce {
+		args = append(args, "-stream_loop", "-1")
+	}
+
+	// FFmpeg 内置的 VP8/VP9 解码器会丢弃透明通道, 需要使用 libvpx 解码
+	if strings.ToLower(filepath.Ext(layer.WatermarkPath)) == ".webm" {
+		args = append(args, "-codec:v", webmDecoder(layer.WatermarkPath))
+	}
+
+	return args
+}
+
+// playbackOption 设置动态水印的 overlay 结束行为选项
+//
+// 静态图片使用 overlay 默认行为 (重复最后一帧); 循环播放时随主画面结束;
+// 只播放一次时在水印结束后直接输出主画面
+func playbackOption(overlay *filtergraph.Filter, layer WatermarkLayer) {
+	if !isAnimatedWatermark(layer.WatermarkPath) {
+		// 带透明度效果的静态图片被循环读取, 同样需要随主画面结束
+		if hasAlphaEffects(layer) {
+			overlay.Set("shortest", 1)
+		}
+		return
+	}
+	if layer.Playback == playbackOnce {
+		overlay.Set("eof_action", "pass")
+		return
+	}
+	overlay.Set("shortest", 1)
+}
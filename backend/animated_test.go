@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pngChunk 构造一个 PNG 块, CRC 不参与检测, 填 0
+func pngChunk(kind string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, data...)
+	return append(chunk, 0, 0, 0, 0)
+}
+
+func TestIsAnimatedWatermarkAPNG(t *testing.T) {
+	dir := t.TempDir()
+
+	var still bytes.Buffer
+	if err := png.Encode(&still, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	animated := append([]byte(nil), pngSignature...)
+	animated = append(animated, pngChunk("IHDR", make([]byte, 13))...)
+	animated = append(animated, pngChunk("acTL", make([]byte, 8))...)
+	animated = append(animated, pngChunk("IDAT", nil)...)
+	animated = append(animated, pngChunk("IEND", nil)...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"still.png", still.Bytes(), false},
+		{"animated.png", animated, true},
+		{"truncated.png", animated[:20], false},
+		{"not-a-png.png", []byte("fake media"), false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, tt.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := isAnimatedWatermark(path); got != tt.want {
+			t.Errorf("%s: 得到 %v, 期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebmDecoder(t *testing.T) {
+	useFakeRunner(t, fakeScript{match: "vp8.webm", stdout: `{"streams": [{"codec_name": "vp8", "codec_type": "video"}], "format": {}}`})
+
+	vp8 := filepath.Join(t.TempDir(), "vp8.webm")
+	writeTestFile(t, vp8)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{vp8, "libvpx"},
+		{"missing.webm", "libvpx-vp9"},
+	}
+
+	for _, tt := range tests {
+		if got := webmDecoder(tt.path); got != tt.want {
+			t.Errorf("%s: 得到 %q, 期望 %q", tt.path, got, tt.want)
+		}
+	}
+}
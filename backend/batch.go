@@ -0,0 +1,407 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 批量任务管理器
+var (
+	batchManager = struct {
+		tasks map[string]*BatchTask
+		mutex sync.RWMutex
+	}{
+		tasks: make(map[string]*BatchTask),
+	}
+)
+
+// 文件名中不安全的字符, 渲染输出路径时替换为下划线
+var unsafeNameRegex = regexp.MustCompile(`[^\p{L}\p{N}._@+-]+`)
+
+// BatchTask 按接收者批量生成个性化输出的任务, 每个输出依次作为一个 FFmpegTask 执行
+type BatchTask struct {
+	ID       string
+	Status   *BatchStatus
+	DoneChan chan bool
+	Mutex    sync.Mutex
+
+	req    BatchRequest
+	width  int // 源画面宽度, 用于渲染接收者文字水印
+	height int // 源画面高度
+}
+
+// parseRecipients 合并请求中的接收者列表和 CSV 接收者列表
+//
+// CSV 第一行为表头, 按列名 (不区分大小写) 读取 id、name、email 列
+func parseRecipients(req BatchRequest) ([]Recipient, error) {
+	recipients := append([]Recipient(nil), req.Recipients...)
+
+	if strings.TrimSpace(req.RecipientsCSV) != "" {
+		reader := csv.NewReader(strings.NewReader(req.RecipientsCSV))
+		reader.TrimLeadingSpace = true
+
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv header: %v", err)
+		}
+		columns := map[string]int{}
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := columns["id"]; !ok {
+			if _, ok := columns["name"]; !ok {
+				return nil, fmt.Errorf("csv must have an id or name column")
+			}
+		}
+
+		field := func(record []string, name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read csv: %v", err)
+			}
+			recipients = append(recipients, Recipient{
+				ID:    field(record, "id"),
+				Name:  field(record, "name"),
+				Email: field(record, "email"),
+			})
+		}
+	}
+
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients")
+	}
+	for i, r := range recipients {
+		if r.ID == "" && r.Name == "" {
+			return nil, fmt.Errorf("recipient %d: id or name is required", i+1)
+		}
+	}
+
+	return recipients, nil
+}
+
+// expandTemplate 将模板中的 {id}、{name}、{email}、{index} 替换为接收者信息, sanitize 为 true 时替换文件名中不安全的字符
+func expandTemplate(template string, r Recipient, index int, sanitize bool) string {
+	value := func(s string) string {
+		if sanitize {
+			return strings.Trim(unsafeNameRegex.ReplaceAllString(s, "_"), "_")
+		}
+		return s
+	}
+
+	return strings.NewReplacer(
+		"{id}", value(r.ID),
+		"{name}", value(r.Name),
+		"{email}", value(r.Email),
+		"{index}", strconv.Itoa(index+1),
+	).Replace(template)
+}
+
+// manifestPath 返回清单文件路径, 未指定时写入输出目录下的 manifest.json
+func manifestPath(req BatchRequest) (string, error) {
+	if req.ManifestPath != "" {
+		return req.ManifestPath, nil
+	}
+
+	dir := filepath.Dir(req.OutputTemplate)
+	if strings.Contains(dir, "{") {
+		return "", fmt.Errorf("manifest path is required when the output directory depends on the recipient")
+	}
+	return filepath.Join(dir, "manifest.json"), nil
+}
+
+// buildRecipientRequest 构建单个接收者的处理请求: 基础水印层之上叠加接收者文字水印
+func buildRecipientRequest(req BatchRequest, r Recipient, index int, textPath string) ProcessRequest {
+	layers := append([]WatermarkLayer(nil), req.Layers...)
+	layers = append(layers, WatermarkLayer{
+		WatermarkPath: textPath,
+		Position:      "top-left",
+		Scale:         100,
+		Opacity:       100,
+	})
+
+	return ProcessRequest{
+		SourcePath: req.SourcePath,
+		OutputPath: expandTemplate(req.OutputTemplate, r, index, true),
+		Layers:     layers,
+		Regions:    req.Regions,
+		Encoding:   req.Encoding,
+		Image:      req.Image,
+	}
+}
+
+// validateBatchRequest 校验批量处理请求, 包括每个接收者的输出路径和处理参数
+func validateBatchRequest(req BatchRequest) error {
+	if req.SourcePath == "" {
+		return fmt.Errorf("source path is required")
+	}
+	if req.OutputTemplate == "" {
+		return fmt.Errorf("output template is required")
+	}
+	if req.Text.Text == "" {
+		return fmt.Errorf("recipient text is required")
+	}
+	if _, err := manifestPath(req); err != nil {
+		return err
+	}
+
+	recipients, err := parseRecipients(req)
+	if err != nil {
+		return err
+	}
+
+	// 每个接收者的输出路径必须不同, 否则会互相覆盖
+	outputs := make(map[string]int, len(recipients))
+	for i, r := range recipients {
+		processReq := buildRecipientRequest(req, r, i, "recipient.png")
+		if prev, ok := outputs[processReq.OutputPath]; ok {
+			return fmt.Errorf("recipients %d and %d have the same output path: %q", prev+1, i+1, processReq.OutputPath)
+		}
+		outputs[processReq.OutputPath] = i
+
+		if err := validateProcessParams(processReq); err != nil {
+			return fmt.Errorf("recipient %d: %v", i+1, err)
+		}
+	}
+
+	// 所有接收者的处理命令只有文字水印不同, 只需检查一次 FFmpeg 能力
+	if len(recipients) > 0 {
+		return checkCapabilities(buildRecipientRequest(req, recipients[0], 0, "recipient.png"))
+	}
+	return nil
+}
+
+// NewBatchTask 创建批量处理任务
+func NewBatchTask(req BatchRequest) (*BatchTask, error) {
+	batchID := fmt.Sprintf("batch_%d", time.Now().UnixNano())
+
+	recipients, err := parseRecipients(req)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := manifestPath(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 接收者文字水印与旋转、摆正后的画面等大
+	width, height, err := probeDisplayResolution(req.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]BatchItem, len(recipients))
+	for i, r := range recipients {
+		items[i] = BatchItem{
+			Index:      i + 1,
+			Recipient:  r,
+			OutputPath: expandTemplate(req.OutputTemplate, r, i, true),
+			Status:     "pending",
+		}
+	}
+
+	status := &BatchStatus{
+		ID:           batchID,
+		Status:       "pending",
+		Total:        len(items),
+		ManifestPath: manifest,
+		Items:        items,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	return &BatchTask{
+		ID:       batchID,
+		Status:   status,
+		DoneChan: make(chan bool),
+		req:      req,
+		width:    width,
+		height:   height,
+	}, nil
+}
+
+// Start 启动批量任务, 按顺序处理每个接收者
+func (b *BatchTask) Start() {
+	b.Mutex.Lock()
+	b.Status.Status = "processing"
+	b.Status.UpdatedAt = time.Now()
+	b.Mutex.Unlock()
+
+	slog.Info("批量任务启动", "batchID", b.ID, "recipients", b.Status.Total)
+
+	go func() {
+		for i := range b.Status.Items {
+			b.processItem(i)
+		}
+
+		b.Mutex.Lock()
+		if b.Status.Failed > 0 {
+			b.Status.Status = "failed"
+			b.Status.Error = fmt.Sprintf("%d of %d outputs failed", b.Status.Failed, b.Status.Total)
+		} else {
+			b.Status.Status = "completed"
+		}
+		b.Status.UpdatedAt = time.Now()
+		b.Mutex.Unlock()
+
+		b.writeManifest()
+		close(b.DoneChan)
+	}()
+}
+
+// processItem 处理单个接收者, 结果记录在对应的 BatchItem 中
+func (b *BatchTask) processItem(i int) {
+	b.Mutex.Lock()
+	item := b.Status.Items[i]
+	b.Mutex.Unlock()
+
+	item.Status = "processing"
+	b.updateItem(i, item)
+
+	taskID, err := b.runItem(i, item.Recipient)
+	item.TaskID = taskID
+	if err == nil {
+		item.Size, item.SHA256, err = hashFile(item.OutputPath)
+	}
+
+	if err != nil {
+		item.Status = "failed"
+		item.Error = err.Error()
+		slog.Error("批量任务输出失败", "batchID", b.ID, "index", i+1, "error", err)
+	} else {
+		item.Status = "completed"
+	}
+	b.updateItem(i, item)
+	b.writeManifest()
+}
+
+// runItem 渲染接收者文字水印并执行处理任务, 返回处理任务 ID
+func (b *BatchTask) runItem(i int, r Recipient) (string, error) {
+	settings := b.req.Text
+	settings.Text = expandTemplate(settings.Text, r, i, false)
+
+	img, err := renderTextWatermark(settings, b.width, b.height)
+	if err != nil {
+		return "", fmt.Errorf("failed to render watermark: %v", err)
+	}
+
+	if err := os.MkdirAll(AppConfig.TempPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	textPath := filepath.Join(AppConfig.TempPath, fmt.Sprintf("%s_%d.png", b.ID, i+1))
+	file, err := os.Create(textPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create watermark image: %v", err)
+	}
+	err = png.Encode(file, img)
+	file.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode watermark image: %v", err)
+	}
+	defer os.Remove(textPath)
+
+	req := buildRecipientRequest(b.req, r, i, textPath)
+	if err := os.MkdirAll(filepath.Dir(req.OutputPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	task, err := NewFFmpegTask(req)
+	if err != nil {
+		return "", err
+	}
+
+	// 每个输出同时作为普通处理任务登记, 可以通过 /api/process/:taskId 查看详细进度
+	taskManager.mutex.Lock()
+	taskManager.tasks[task.ID] = task
+	taskManager.mutex.Unlock()
+
+	// 经由任务队列启动, 与其他处理任务共享并发名额
+	taskQueue.Enqueue(task)
+	<-task.DoneChan
+
+	if status := task.GetStatus(); status.Status == "failed" {
+		return task.ID, fmt.Errorf("%s", status.Error)
+	}
+	return task.ID, nil
+}
+
+// updateItem 更新单个接收者的状态和批量任务的计数
+func (b *BatchTask) updateItem(i int, item BatchItem) {
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	b.Status.Items[i] = item
+	switch item.Status {
+	case "completed":
+		b.Status.Completed++
+	case "failed":
+		b.Status.Failed++
+	}
+	b.Status.UpdatedAt = time.Now()
+}
+
+// writeManifest 将当前状态写入清单文件, 每个输出完成后更新一次
+func (b *BatchTask) writeManifest() {
+	status := b.GetStatus()
+
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		slog.Error("生成清单失败", "batchID", b.ID, "error", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(status.ManifestPath), 0755); err != nil {
+		slog.Error("创建清单目录失败", "batchID", b.ID, "error", err)
+		return
+	}
+	if err := os.WriteFile(status.ManifestPath, data, 0644); err != nil {
+		slog.Error("写入清单失败", "batchID", b.ID, "error", err)
+	}
+}
+
+// GetStatus 获取批量任务状态的副本
+func (b *BatchTask) GetStatus() BatchStatus {
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	status := *b.Status
+	status.Items = append([]BatchItem(nil), b.Status.Items...)
+	return status
+}
+
+// hashFile 计算文件大小和 SHA-256
+func hashFile(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRecipients(t *testing.T) {
+	req := BatchRequest{
+		Recipients:    []Recipient{{ID: "r1", Name: "Alice"}},
+		RecipientsCSV: "Name, Email, ID\nBob, bob@example.com, r2\n\"Carol, Jr.\",,r3\n",
+	}
+
+	want := []Recipient{
+		{ID: "r1", Name: "Alice"},
+		{ID: "r2", Name: "Bob", Email: "bob@example.com"},
+		{ID: "r3", Name: "Carol, Jr."},
+	}
+
+	got, err := parseRecipients(req)
+	if err != nil {
+		t.Fatalf("解析接收者失败: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("接收者错误:\n得到 %+v\n期望 %+v", got, want)
+	}
+
+	errorTests := []struct {
+		name string
+		req  BatchRequest
+	}{
+		{"没有接收者", BatchRequest{}},
+		{"缺少 id 和 name 列", BatchRequest{RecipientsCSV: "email\na@example.com\n"}},
+		{"接收者缺少 id 和 name", BatchRequest{Recipients: []Recipient{{Email: "a@example.com"}}}},
+	}
+	for _, tt := range errorTests {
+		if _, err := parseRecipients(tt.req); err == nil {
+			t.Errorf("%s: 应返回错误", tt.name)
+		}
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	r := Recipient{ID: "42", Name: "Zoë O'Brien / Press", Email: "zoe@example.com"}
+
+	tests := []struct {
+		template string
+		sanitize bool
+		want     string
+	}{
+		{"out/{index}_{id}.mp4", true, "out/3_42.mp4"},
+		{"out/{name}.mp4", true, "out/Zoë_O_Brien_Press.mp4"},
+		{"out/{email}.mp4", true, "out/zoe@example.com.mp4"},
+		{"For {name} ({id})", false, "For Zoë O'Brien / Press (42)"},
+	}
+
+	for _, tt := range tests {
+		if got := expandTemplate(tt.template, r, 2, tt.sanitize); got != tt.want {
+			t.Errorf("%q: 得到 %q, 期望 %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestManifestPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     BatchRequest
+		want    string
+		wantErr bool
+	}{
+		{"默认路径", BatchRequest{OutputTemplate: "out/{id}.mp4"}, "out/manifest.json", false},
+		{"指定路径", BatchRequest{OutputTemplate: "out/{id}/a.mp4", ManifestPath: "out/list.json"}, "out/list.json", false},
+		{"目录含占位符", BatchRequest{OutputTemplate: "out/{id}/a.mp4"}, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := manifestPath(tt.req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: 错误 %v, 期望返回错误 %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: 得到 %q, 期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildRecipientRequest(t *testing.T) {
+	logo := WatermarkLayer{WatermarkPath: "logo.png", Position: "top-right", Scale: 20, Opacity: 80}
+	req := BatchRequest{
+		SourcePath:     "film.mp4",
+		OutputTemplate: "out/{id}.mp4",
+		Layers:         []WatermarkLayer{logo},
+		Encoding:       EncodingOptions{CRF: 20},
+	}
+
+	got := buildRecipientRequest(req, Recipient{ID: "r7"}, 0, "temp/text.png")
+	want := ProcessRequest{
+		SourcePath: "film.mp4",
+		OutputPath: "out/r7.mp4",
+		Layers: []WatermarkLayer{
+			logo,
+			{WatermarkPath: "temp/text.png", Position: "top-left", Scale: 100, Opacity: 100},
+		},
+		Encoding: EncodingOptions{CRF: 20},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("处理请求错误:\n得到 %+v\n期望 %+v", got, want)
+	}
+}
+
+func TestValidateBatchRequest(t *testing.T) {
+	base := BatchRequest{
+		SourcePath:     "film.mp4",
+		OutputTemplate: "out/{id}.mp4",
+		Recipients:     []Recipient{{ID: "a"}, {ID: "b"}},
+		Text:           WatermarkSettings{Text: "{name} {id}", FontSize: 24},
+	}
+	if err := validateBatchRequest(base); err != nil {
+		t.Errorf("有效请求不应返回错误: %v", err)
+	}
+
+	duplicate := base
+	duplicate.OutputTemplate = "out/{name}.mp4"
+	if err := validateBatchRequest(duplicate); err == nil {
+		t.Error("输出路径重复时应返回错误")
+	}
+
+	noText := base
+	noText.Text.Text = ""
+	if err := validateBatchRequest(noText); err == nil {
+		t.Error("缺少接收者文字时应返回错误")
+	}
+}
+
+func TestNewBatchTaskRotatedSource(t *testing.T) {
+	useFakeRunner(t, fakeScript{match: "-show_streams", stdout: probePortraitJSON})
+
+	source := filepath.Join(t.TempDir(), "portrait.mp4")
+	writeTestFile(t, source)
+
+	req := BatchRequest{
+		SourcePath:     source,
+		OutputTemplate: filepath.Join(t.TempDir(), "{id}.mp4"),
+		Recipients:     []Recipient{{ID: "a"}},
+		Text:           WatermarkSettings{Text: "{id}", FontSize: 24},
+	}
+	b, err := NewBatchTask(req)
+	if err != nil {
+		t.Fatalf("创建批量任务失败: %v", err)
+	}
+
+	// 接收者文字水印按自动旋转后的竖屏画面渲染
+	if b.width != 1080 || b.height != 1920 {
+		t.Errorf("文字水印尺寸: 得到 %dx%d, 期望 1080x1920", b.width, b.height)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"ffwatermark/filtergraph"
+)
+
+// 水印混合模式, 取值与 FFmpeg blend 滤镜的模式名称一致
+const blendNormal = "normal"
+
+var blendModes = map[string]bool{
+	"multiply":  true,
+	"screen":    true,
+	"overlay":   true,
+	"softlight": true,
+}
+
+// isBlendMode 判断是否需要使用混合模式 (normal 使用普通的 overlay 叠加)
+func isBlendMode(mode string) bool {
+	return blendModes[mode]
+}
+
+// validateBlend 校验混合模式
+func validateBlend(mode string) error {
+	if mode == "" || mode == blendNormal || isBlendMode(mode) {
+		return nil
+	}
+	return fmt.Errorf("invalid blend mode: %q", mode)
+}
+
+// opacityFilters 返回调整水印透明度的滤镜, 通过缩放透明通道实现, 不透明时返回空
+//
+// 滤镜要求输入为 rgba 格式, 由调用方负责转换
+func opacityFilters(opacity int) []*filtergraph.Filter {
+	if opacity >= 100 {
+		return nil
+	}
+	return []*filtergraph.Filter{filtergraph.New("colorchannelmixer").Set("aa", formatNumber(float64(opacity)/100))}
+}
+
+// buildBlendChains 构建带混合模式的叠加滤镜链, overlay 为设置好位置等参数的叠加滤镜
+//
+// blend 滤镜要求两个输入尺寸相同, 因此先把水印叠加到与主画面等大的透明画布上,
+// 用混合模式合成整个画面, 再用水印的透明通道作为遮罩把混合结果叠加回主画面
+func buildBlendChains(mode string, input int, main, wm string, overlay *filtergraph.Filter, out string) *filtergraph.Graph {
+	label := func(name string) string {
+		return fmt.Sprintf("%s%d", name, input)
+	}
+
+	var g filtergraph.Graph
+	g.Chain(main).Then(filtergraph.New("split", "3")).To(label("base"), label("blendbase"), label("canvassrc"))
+	g.Chain(label("canvassrc")).Then(
+		filtergraph.New("format", "rgba"),
+		filtergraph.New("drawbox").Set("x", 0).Set("y", 0).Set("w", "iw").Set("h", "ih").
+			Set("color", "black@0").Set("t", "fill").Set("replace", 1),
+	).To(label("canvas"))
+	g.Chain(label("canvas"), wm).Then(overlay.Set("format", "auto")).To(label("layer"))
+	g.Chain(label("layer")).Then(filtergraph.New("split")).To(label("layercolor"), label("layeralpha"))
+	g.Chain(label("blendbase")).Then(filtergraph.New("format", "rgba")).To(label("blendsrc"))
+	g.Chain(label("blendsrc"), label("layercolor")).Then(filtergraph.New("blend").Set("all_mode", mode)).To(label("blended"))
+	g.Chain(label("layeralpha")).Then(filtergraph.New("alphaextract")).To(label("mask"))
+	g.Chain(label("blended"), label("mask")).Then(filtergraph.New("alphamerge")).To(label("top"))
+
+	last := g.Chain(label("base"), label("top")).Then(filtergraph.New("overlay"))
+	if out != "" {
+		last.To(out)
+	}
+
+	return &g
+}
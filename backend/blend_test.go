@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOpacityFilters(t *testing.T) {
+	if filters := opacityFilters(100); filters != nil {
+		t.Errorf("不透明水印不应添加滤镜: %v", filters)
+	}
+	if got := joinFilters(opacityFilters(35)); got != "colorchannelmixer=aa=0.35" {
+		t.Errorf("透明度滤镜错误: %q", got)
+	}
+}
+
+func TestBuildLayerFilterBlend(t *testing.T) {
+	layer := WatermarkLayer{WatermarkPath: "logo.png", Position: "center", Scale: 100, Opacity: 100, Blend: "multiply"}
+
+	want := strings.Join([]string{
+		"[1]scale=iw*100/100:-1[wm1]",
+		"[0]split=3[base1][blendbase1][canvassrc1]",
+		"[canvassrc1]format=rgba,drawbox=x=0:y=0:w=iw:h=ih:color=black@0:t=fill:replace=1[canvas1]",
+		"[canvas1][wm1]overlay=x=(main_w-overlay_w)/2:y=(main_h-overlay_h)/2:format=auto[layer1]",
+		"[layer1]split[layercolor1][layeralpha1]",
+		"[blendbase1]format=rgba[blendsrc1]",
+		"[blendsrc1][layercolor1]blend=all_mode=multiply[blended1]",
+		"[layeralpha1]alphaextract[mask1]",
+		"[blended1][mask1]alphamerge[top1]",
+		"[base1][top1]overlay[v1]",
+	}, ";")
+
+	if got := buildLayerFilter(layer, 1, "0", "v1").String(); got != want {
+		t.Errorf("混合模式滤镜错误:\n得到 %q\n期望 %q", got, want)
+	}
+}
+
+func TestValidateBlend(t *testing.T) {
+	for _, mode := range []string{"", "normal", "multiply", "screen", "overlay", "softlight"} {
+		if err := validateBlend(mode); err != nil {
+			t.Errorf("%q: 不应返回错误: %v", mode, err)
+		}
+	}
+	if err := validateBlend("dodge"); err == nil {
+		t.Error("未知混合模式应返回错误")
+	}
+}
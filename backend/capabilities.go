@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FFmpeg 能力, 启动时探测, 探测失败时在首次查询时重试
+var (
+	capabilities = struct {
+		caps  *FFmpegCapabilities
+		mutex sync.RWMutex
+	}{}
+)
+
+// DiscoverCapabilities 探测 FFmpeg 的版本、编码器、滤镜和硬件加速方式
+func DiscoverCapabilities() error {
+	version, err := runFFmpegQuery("-version")
+	if err != nil {
+		return err
+	}
+	encoders, err := runFFmpegQuery("-hide_banner", "-encoders")
+	if err != nil {
+		return err
+	}
+	decoders, err := runFFmpegQuery("-hide_banner", "-decoders")
+	if err != nil {
+		return err
+	}
+	filters, err := runFFmpegQuery("-hide_banner", "-filters")
+	if err != nil {
+		return err
+	}
+	hwaccels, err := runFFmpegQuery("-hide_banner", "-hwaccels")
+	if err != nil {
+		return err
+	}
+
+	caps := &FFmpegCapabilities{
+		Path:   AppConfig.FFmpegPath,
+		Source: AppConfig.FFmpeg.Source,
+		Reason: AppConfig.FFmpeg.Reason,
+	}
+	caps.Version, caps.Configuration = parseVersionOutput(version)
+	caps.Encoders, caps.Codecs = parseEncodersOutput(encoders)
+	caps.Decoders, _ = parseEncodersOutput(decoders)
+	caps.Filters = parseFiltersOutput(filters)
+	caps.HWAccels = parseHWAccelsOutput(hwaccels)
+
+	if caps.Version == "" {
+		return fmt.Errorf("unrecognized ffmpeg -version output")
+	}
+
+	capabilities.mutex.Lock()
+	capabilities.caps = caps
+	capabilities.mutex.Unlock()
+
+	return nil
+}
+
+// getCapabilities 返回已探测的 FFmpeg 能力, 尚未探测成功时返回 nil
+func getCapabilities() *FFmpegCapabilities {
+	capabilities.mutex.RLock()
+	defer capabilities.mutex.RUnlock()
+	return capabilities.caps
+}
+
+// runFFmpegQuery 运行 FFmpeg 查询命令并返回标准输出
+func runFFmpegQuery(args ...string) (string, error) {
+	out, err := runner.Command(AppConfig.FFmpegPath, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run ffmpeg %s: %v", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// parseVersionOutput 解析 ffmpeg -version 的输出, 返回版本号和编译配置
+//
+// 第一行形如 "ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers"
+func parseVersionOutput(out string) (string, string) {
+	var version, configuration string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(line, "ffmpeg version "); ok && version == "" {
+			version, _, _ = strings.Cut(rest, " ")
+		}
+		if rest, ok := strings.CutPrefix(line, "configuration:"); ok {
+			configuration = strings.TrimSpace(rest)
+		}
+	}
+	return version, configuration
+}
+
+// parseEncodersOutput 解析 ffmpeg -encoders 的输出, 返回编码器名称和可编码的编解码器名称;
+// ffmpeg -decoders 的输出格式相同
+//
+// 编码器行位于 "------" 分隔行之后, 形如 " V....D libx264   libx264 H.264 ... (codec h264)",
+// 描述末尾的 (codec xxx) 为编码器名称与编解码器名称不同时对应的编解码器
+func parseEncodersOutput(out string) ([]string, []string) {
+	encoders := make([]string, 0)
+	codecs := make([]string, 0)
+
+	started := false
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if !started {
+			started = len(fields) == 1 && strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		if len(fields) < 2 {
+			continue
+		}
+
+		name := fields[1]
+		encoders = append(encoders, name)
+
+		codec := name
+		if i := strings.LastIndex(line, "(codec "); i >= 0 {
+			codec = strings.TrimSuffix(strings.TrimSpace(line[i+len("(codec "):]), ")")
+		}
+		if !slices.Contains(codecs, codec) {
+			codecs = append(codecs, codec)
+		}
+	}
+
+	return encoders, codecs
+}
+
+// parseFiltersOutput 解析 ffmpeg -filters 的输出, 返回滤镜名称
+//
+// 滤镜行形如 " TSC overlay           VV->V      Overlay a video source on top of the input.",
+// 第三列为输入输出类型, 以此区分说明行
+func parseFiltersOutput(out string) []string {
+	filters := make([]string, 0)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && strings.Contains(fields[2], "->") {
+			filters = append(filters, fields[1])
+		}
+	}
+	return filters
+}
+
+// parseHWAccelsOutput 解析 ffmpeg -hwaccels 的输出, 返回硬件加速方式
+func parseHWAccelsOutput(out string) []string {
+	hwaccels := make([]string, 0)
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		hwaccels = append(hwaccels, line)
+	}
+	return hwaccels
+}
+
+// imageEncoders 静态图片输出格式对应的编码器, 由 FFmpeg 按输出文件扩展名选择
+var imageEncoders = map[string]string{
+	".jpg":  "mjpeg",
+	".jpeg": "mjpeg",
+	".png":  "png",
+	".bmp":  "bmp",
+	".gif":  "gif",
+}
+
+// requiredEncoders 返回处理请求需要的编码器名称
+//
+// 视频按编码设置检查, 未指定时由 FFmpeg 按容器选择默认编码器, 不检查;
+// 静态图片只输出单帧, 按扩展名检查图片编码器; 不可见水印的解码进程以 rawvideo 输出原始帧
+func requiredEncoders(req ProcessRequest) []string {
+	var encoders []string
+	if isStillImage(req.SourcePath) {
+		if encoder, ok := imageEncoders[strings.ToLower(filepath.Ext(req.OutputPath))]; ok {
+			encoders = append(encoders, encoder)
+		}
+	} else {
+		for _, codec := range []string{req.Encoding.VideoCodec, req.Encoding.AudioCodec} {
+			if codec != "" && codec != "copy" {
+				encoders = append(encoders, codec)
+			}
+		}
+	}
+
+	if _, ok := invisibleLayer(req); ok {
+		encoders = append(encoders, "rawvideo")
+	}
+	return encoders
+}
+
+// requiredDecoders 返回处理请求指定的解码器名称; 其余输入由 FFmpeg 自动选择解码器, 不检查
+func requiredDecoders(req ProcessRequest) []string {
+	var decoders []string
+	for _, layer := range visibleLayers(req) {
+		if isAnimatedWatermark(layer.WatermarkPath) && strings.ToLower(filepath.Ext(layer.WatermarkPath)) == ".webm" {
+			decoders = append(decoders, webmDecoder(layer.WatermarkPath))
+		}
+	}
+	return decoders
+}
+
+// requiredFilters 返回处理请求需要的滤镜名称
+func requiredFilters(req ProcessRequest) []string {
+	// 不可见水印只在解码阶段叠加可见水印层和去除区域
+	visible := req
+	visible.Layers = visibleLayers(req)
+	if len(visible.Layers) == 0 {
+		if len(req.Regions) == 0 {
+			return nil
+		}
+		return buildRegionChains(req.Regions, "0", "").FilterNames()
+	}
+
+	return buildFilterGraph(visible).FilterNames()
+}
+
+// checkCapabilities 检查 FFmpeg 是否支持请求需要的编码器、解码器和滤镜; 未探测到 FFmpeg 能力时不检查
+func checkCapabilities(req ProcessRequest) error {
+	// 未探测时不计算需要的滤镜和解码器, 避免运行 ffprobe
+	if getCapabilities() == nil {
+		return nil
+	}
+	return checkRequired(requiredEncoders(req), requiredDecoders(req), requiredFilters(req))
+}
+
+// checkVerifyCapabilities 检查 FFmpeg 是否支持水印校验的采样命令; 未探测到 FFmpeg 能力时不检查
+func checkVerifyCapabilities(req VerifyRequest) error {
+	return checkRequired([]string{"rawvideo"}, nil, buildVerifyGraph(req, 1, 1).FilterNames())
+}
+
+// checkRequired 检查编码器、解码器和滤镜是否都可用
+func checkRequired(encoders, decoders, filters []string) error {
+	caps := getCapabilities()
+	if caps == nil {
+		return nil
+	}
+
+	for _, encoder := range encoders {
+		if !slices.Contains(caps.Encoders, encoder) && !slices.Contains(caps.Codecs, encoder) {
+			return fmt.Errorf("encoder %q is not available in FFmpeg %s", encoder, caps.Version)
+		}
+	}
+
+	for _, decoder := range decoders {
+		if !slices.Contains(caps.Decoders, decoder) {
+			return fmt.Errorf("decoder %q is not available in FFmpeg %s", decoder, caps.Version)
+		}
+	}
+
+	var missing []string
+	for _, name := range filters {
+		if !slices.Contains(caps.Filters, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("filters not available in FFmpeg %s: %s", caps.Version, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
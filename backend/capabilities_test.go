@@ -0,0 +1,153 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseVersionOutput(t *testing.T) {
+	out := "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\n" +
+		"built with gcc 13 (Ubuntu 13.2.0-23ubuntu3)\n" +
+		"configuration: --prefix=/usr --enable-gpl --enable-libx264\n" +
+		"libavutil      58. 29.100 / 58. 29.100\n"
+
+	version, configuration := parseVersionOutput(out)
+	if version != "6.1.1-3ubuntu5" {
+		t.Errorf("版本号: 得到 %q, 期望 %q", version, "6.1.1-3ubuntu5")
+	}
+	if configuration != "--prefix=/usr --enable-gpl --enable-libx264" {
+		t.Errorf("编译配置: 得到 %q", configuration)
+	}
+
+	if version, _ := parseVersionOutput("not ffmpeg\n"); version != "" {
+		t.Errorf("无效输出: 得到版本号 %q, 期望为空", version)
+	}
+}
+
+func TestParseEncodersOutput(t *testing.T) {
+	out := "Encoders:\n" +
+		" V..... = Video\n" +
+		" A..... = Audio\n" +
+		" .....D = Supports direct rendering method 1\n" +
+		" ------\n" +
+		" V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)\n" +
+		" V....D h264_nvenc           NVIDIA NVENC H.264 encoder (codec h264)\n" +
+		" V....D png                  PNG (Portable Network Graphics) image\n" +
+		" A....D aac                  AAC (Advanced Audio Coding)\n"
+
+	encoders, codecs := parseEncodersOutput(out)
+	if want := []string{"libx264", "h264_nvenc", "png", "aac"}; !reflect.DeepEqual(encoders, want) {
+		t.Errorf("编码器: 得到 %v, 期望 %v", encoders, want)
+	}
+	if want := []string{"h264", "png", "aac"}; !reflect.DeepEqual(codecs, want) {
+		t.Errorf("编解码器: 得到 %v, 期望 %v", codecs, want)
+	}
+}
+
+func TestParseFiltersOutput(t *testing.T) {
+	out := "Filters:\n" +
+		"  T.. = Timeline support\n" +
+		"  .S. = Slice threading\n" +
+		"  A = Audio input/output\n" +
+		"  | = Source or sink filter\n" +
+		" ... abench            A->A       Benchmark part of a filtergraph.\n" +
+		" TSC overlay           VV->V      Overlay a video source on top of the input.\n" +
+		" ..C scale2ref         VV->VV     Scale the input video size and/or convert the image format to the given reference.\n" +
+		" ... color             |->V       Provide an uniformly colored input.\n"
+
+	want := []string{"abench", "overlay", "scale2ref", "color"}
+	if got := parseFiltersOutput(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("滤镜: 得到 %v, 期望 %v", got, want)
+	}
+}
+
+func TestParseHWAccelsOutput(t *testing.T) {
+	out := "Hardware acceleration methods:\nvdpau\ncuda\nvaapi\n\n"
+
+	want := []string{"vdpau", "cuda", "vaapi"}
+	if got := parseHWAccelsOutput(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("硬件加速: 得到 %v, 期望 %v", got, want)
+	}
+}
+
+func TestCheckCapabilities(t *testing.T) {
+	defer func(caps *FFmpegCapabilities) { capabilities.caps = caps }(capabilities.caps)
+
+	layer := WatermarkLayer{WatermarkPath: "logo.png", Scale: 50, Opacity: 100}
+	base := ProcessRequest{SourcePath: "in.mp4", OutputPath: "out.mp4", WatermarkLayer: layer}
+
+	withEncoding := func(enc EncodingOptions) ProcessRequest {
+		req := base
+		req.Encoding = enc
+		return req
+	}
+	withRegions := func(regions ...RemovalRegion) ProcessRequest {
+		req := base
+		req.Regions = regions
+		return req
+	}
+	withImage := func(output string) ProcessRequest {
+		req := base
+		req.SourcePath, req.OutputPath = "in.jpg", output
+		return req
+	}
+	withWatermark := func(path string) ProcessRequest {
+		req := base
+		req.WatermarkLayer.WatermarkPath = path
+		return req
+	}
+
+	// 未探测时不检查, 也不为 WebM 水印运行 ffprobe
+	r := useFakeRunner(t)
+	webm := filepath.Join(t.TempDir(), "sting.webm")
+	writeTestFile(t, webm)
+	capabilities.caps = nil
+	if err := checkCapabilities(withEncoding(EncodingOptions{VideoCodec: "libsvtav1"})); err != nil {
+		t.Errorf("未探测: 错误 %v, 期望不检查", err)
+	}
+	if err := checkCapabilities(withWatermark(webm)); err != nil || len(r.Commands()) != 0 {
+		t.Errorf("未探测: 错误 %v, 执行了命令 %q", err, r.Commands())
+	}
+
+	capabilities.caps = &FFmpegCapabilities{
+		Version:  "6.1.1",
+		Encoders: []string{"libx264", "aac", "png"},
+		Codecs:   []string{"h264", "aac", "png"},
+		Decoders: []string{"h264", "libvpx-vp9"},
+		Filters:  []string{"format", "colorchannelmixer", "scale", "overlay", "delogo", "setpts"},
+	}
+
+	invisible := base
+	invisible.Layers = []WatermarkLayer{layer, {Mode: modeInvisible, Payload: "owner"}}
+
+	tests := []struct {
+		name    string
+		req     ProcessRequest
+		wantErr bool
+	}{
+		{"默认编码", base, false},
+		{"编码器名称", withEncoding(EncodingOptions{VideoCodec: "libx264", AudioCodec: "aac"}), false},
+		{"编解码器名称", withEncoding(EncodingOptions{VideoCodec: "h264"}), false},
+		{"复制音频", withEncoding(EncodingOptions{VideoCodec: "libx264", AudioCodec: "copy"}), false},
+		{"不支持的视频编码器", withEncoding(EncodingOptions{VideoCodec: "libsvtav1"}), true},
+		{"不支持的音频编码器", withEncoding(EncodingOptions{AudioCodec: "libopus"}), true},
+		{"支持的去除方式", withRegions(RemovalRegion{X: 10, Y: 10, Width: 100, Height: 50}), false},
+		{"不支持的滤镜", withRegions(RemovalRegion{X: 10, Y: 10, Width: 100, Height: 50, Method: "blur"}), true},
+		{"图片编码器", withImage("out.png"), false},
+		{"不支持的图片编码器", withImage("out.jpg"), true},
+		{"WebM 解码器", withWatermark("sting.webm"), false},
+		{"不可见水印的原始帧输出", invisible, true},
+	}
+
+	for _, tt := range tests {
+		if err := checkCapabilities(tt.req); (err != nil) != tt.wantErr {
+			t.Errorf("%s: 错误 %v, 期望返回错误 %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// 水印校验需要 fps 滤镜和 rawvideo 输出
+	if err := checkVerifyCapabilities(VerifyRequest{SourcePath: "in.mp4", Interval: 1}); err == nil {
+		t.Error("水印校验: 缺少 fps 滤镜和 rawvideo 编码器时应返回错误")
+	}
+}
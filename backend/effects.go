@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"ffwatermark/filtergraph"
+)
+
+// hasAlphaEffects 判断水印层是否设置了随时间变化的透明度效果
+func hasAlphaEffects(layer WatermarkLayer) bool {
+	e := layer.Effects
+	return e.FadeIn > 0 || e.FadeOut > 0 || e.PulsePeriod > 0
+}
+
+// validateEffects 校验淡入淡出和脉冲效果设置
+func validateEffects(e EffectOptions) error {
+	if e.FadeIn < 0 || e.FadeOut < 0 || e.FadeOutEnd < 0 {
+		return fmt.Errorf("invalid fade: in %v, out %v, end %v", e.FadeIn, e.FadeOut, e.FadeOutEnd)
+	}
+	if e.PulsePeriod < 0 {
+		return fmt.Errorf("invalid pulse period: %v", e.PulsePeriod)
+	}
+	if e.PulseMinOpacity < 0 || e.PulseMinOpacity > 100 {
+		return fmt.Errorf("invalid pulse min opacity: %d", e.PulseMinOpacity)
+	}
+	return nil
+}
+
+// fadeInStart 返回淡入开始时间: 水印第一次出现的时间
+func fadeInStart(v VisibilityOptions) float64 {
+	if len(v.Intervals) == 0 {
+		return 0
+	}
+	start := v.Intervals[0].Start
+	for _, r := range v.Intervals[1:] {
+		start = min(start, r.Start)
+	}
+	return start
+}
+
+// needsFadeOutEnd 判断是否需要用视频时长确定淡出结束时间
+func needsFadeOutEnd(layer WatermarkLayer) bool {
+	if layer.Effects.FadeOut <= 0 || layer.Effects.FadeOutEnd > 0 {
+		return false
+	}
+	for _, r := range layer.Visibility.Intervals {
+		if r.End == 0 {
+			return true
+		}
+	}
+	return len(layer.Visibility.Intervals) == 0
+}
+
+// resolveEffects 确定淡出结束时间: 水印最后一次消失的时间, 全程显示时为视频结尾
+//
+// 需要在 resolveVisibility 之后调用, 此时显示时间均为绝对时间
+func resolveEffects(layer *WatermarkLayer, duration float64) error {
+	e := &layer.Effects
+	if e.FadeOut <= 0 || e.FadeOutEnd > 0 {
+		return nil
+	}
+
+	if needsFadeOutEnd(*layer) {
+		if duration <= 0 {
+			return fmt.Errorf("source duration is unknown, cannot resolve fade-out time")
+		}
+		e.FadeOutEnd = duration
+		return nil
+	}
+
+	for _, r := range layer.Visibility.Intervals {
+		e.FadeOutEnd = max(e.FadeOutEnd, r.End)
+	}
+	return nil
+}
+
+// effectFilters 返回按时间调整水印透明通道的滤镜, 没有效果时返回空
+//
+// 淡入、淡出和脉冲都表示为透明度系数, 相乘后作用于每个像素的透明通道; 滤镜要求输入为 rgba 格式
+func effectFilters(layer WatermarkLayer) []*filtergraph.Filter {
+	if !hasAlphaEffects(layer) {
+		return nil
+	}
+
+	e := layer.Effects
+	var factors []string
+	if e.FadeIn > 0 {
+		factors = append(factors, fmt.Sprintf("clip((T-%s)/%s,0,1)", formatNumber(fadeInStart(layer.Visibility)), formatNumber(e.FadeIn)))
+	}
+	if e.FadeOut > 0 && e.FadeOutEnd > 0 {
+		factors = append(factors, fmt.Sprintf("clip((%s-T)/%s,0,1)", formatNumber(e.FadeOutEnd), formatNumber(e.FadeOut)))
+	}
+	if e.PulsePeriod > 0 {
+		// 透明度在 PulseMinOpacity% 与 100% 之间按余弦曲线变化
+		low := float64(e.PulseMinOpacity) / 100
+		factors = append(factors, fmt.Sprintf("(%s+%s*(0.5+0.5*cos(2*PI*T/%s)))", formatNumber(low), formatNumber(1-low), formatNumber(e.PulsePeriod)))
+	}
+	if len(factors) == 0 {
+		return nil
+	}
+
+	return []*filtergraph.Filter{
+		filtergraph.New("geq").
+			Set("r", "r(X,Y)").
+			Set("g", "g(X,Y)").
+			Set("b", "b(X,Y)").
+			Set("a", "alpha(X,Y)*"+strings.Join(factors, "*")),
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildLayerFilterEffects(t *testing.T) {
+	layer := WatermarkLayer{
+		WatermarkPath: "logo.png",
+		Scale:         100,
+		Opacity:       80,
+		Effects:       EffectOptions{FadeIn: 2, FadeOut: 3, FadeOutEnd: 60},
+	}
+
+	want := "[1]format=rgba,colorchannelmixer=aa=0.8," +
+		"geq=r='r(X,Y)':g='g(X,Y)':b='b(X,Y)':a='alpha(X,Y)*clip((T-0)/2,0,1)*clip((60-T)/3,0,1)'," +
+		"scale=iw*100/100:-1[wm1];[0][wm1]overlay=x=0:y=0:shortest=1"
+	if got := buildLayerFilter(layer, 1, "0", "").String(); got != want {
+		t.Errorf("淡入淡出滤镜错误:\n得到 %q\n期望 %q", got, want)
+	}
+
+	// 静态图片需要循环输入才能产生连续的帧
+	if args := strings.Join(watermarkInputArgs(layer), " "); args != "-loop 1" {
+		t.Errorf("输入参数错误: %q", args)
+	}
+}
+
+func TestEffectFiltersPulse(t *testing.T) {
+	layer := WatermarkLayer{Effects: EffectOptions{PulsePeriod: 4, PulseMinOpacity: 25}}
+
+	want := "geq=r='r(X,Y)':g='g(X,Y)':b='b(X,Y)':a='alpha(X,Y)*(0.25+0.75*(0.5+0.5*cos(2*PI*T/4)))'"
+	if got := joinFilters(effectFilters(layer)); got != want {
+		t.Errorf("脉冲滤镜错误:\n得到 %q\n期望 %q", got, want)
+	}
+}
+
+func TestResolveEffects(t *testing.T) {
+	// 全程显示时淡出到视频结尾
+	layer := WatermarkLayer{Effects: EffectOptions{FadeOut: 2}}
+	if err := resolveEffects(&layer, 120); err != nil || layer.Effects.FadeOutEnd != 120 {
+		t.Errorf("淡出结束时间错误: %v, %v", layer.Effects.FadeOutEnd, err)
+	}
+
+	// 有显示时间段时淡出到最后一个时间段结束
+	layer = WatermarkLayer{
+		Effects:    EffectOptions{FadeIn: 1, FadeOut: 2},
+		Visibility: VisibilityOptions{Intervals: []TimeRange{{Start: 5, End: 10}, {Start: 50, End: 60}}},
+	}
+	if err := resolveEffects(&layer, 0); err != nil || layer.Effects.FadeOutEnd != 60 {
+		t.Errorf("淡出结束时间错误: %v, %v", layer.Effects.FadeOutEnd, err)
+	}
+	if start := fadeInStart(layer.Visibility); start != 5 {
+		t.Errorf("淡入开始时间错误: %v", start)
+	}
+
+	// 时长未知时无法确定结尾
+	layer = WatermarkLayer{Effects: EffectOptions{FadeOut: 2}}
+	if err := resolveEffects(&layer, 0); err == nil {
+		t.Error("时长未知时应返回错误")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"ffwatermark/filtergraph"
+)
+
+// 码率格式, 例如 "2500k", "4M", "800000"
+var bitrateRegex = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?[kKmM]?$`)
+
+// validateEncoding 校验输出编码设置
+func validateEncoding(enc EncodingOptions) error {
+	if enc.CRF < 0 || enc.CRF > 63 {
+		return fmt.Errorf("invalid crf: %d", enc.CRF)
+	}
+	if enc.Bitrate != "" && !bitrateRegex.MatchString(enc.Bitrate) {
+		return fmt.Errorf("invalid bitrate: %q", enc.Bitrate)
+	}
+	if enc.CRF > 0 && enc.Bitrate != "" {
+		return fmt.Errorf("crf and bitrate cannot be used together")
+	}
+	if enc.MaxWidth < 0 || enc.MaxHeight < 0 {
+		return fmt.Errorf("invalid max resolution: %dx%d", enc.MaxWidth, enc.MaxHeight)
+	}
+
+	return nil
+}
+
+// buildEncodingArgs 构建输出编码参数
+func buildEncodingArgs(enc EncodingOptions) []string {
+	var args []string
+
+	if enc.VideoCodec != "" {
+		args = append(args, "-codec:v", enc.VideoCodec)
+	}
+
+	// 质量控制: CRF 与目标码率二选一
+	if enc.CRF > 0 {
+		args = append(args, "-crf", strconv.Itoa(enc.CRF))
+	} else if enc.Bitrate != "" {
+		args = append(args, "-b:v", enc.Bitrate)
+	}
+
+	if enc.Preset != "" {
+		args = append(args, "-preset", enc.Preset)
+	}
+	if enc.PixelFormat != "" {
+		args = append(args, "-pix_fmt", enc.PixelFormat)
+	}
+
+	// 音频默认直接复制
+	audioCodec := enc.AudioCodec
+	if audioCodec == "" {
+		audioCodec = "copy"
+	}
+	args = append(args, "-codec:a", audioCodec)
+
+	// 容器参数
+	if enc.FastStart {
+		args = append(args, "-movflags", "+faststart")
+	}
+	if enc.Container != "" {
+		args = append(args, "-f", enc.Container)
+	}
+
+	return args
+}
+
+// buildMaxResolutionFilter 构建限制最大分辨率的缩放滤镜, 只缩小不放大, 保持宽高比; 不限制时返回 nil
+func buildMaxResolutionFilter(enc EncodingOptions) *filtergraph.Filter {
+	switch {
+	case enc.MaxWidth > 0 && enc.MaxHeight > 0:
+		return filtergraph.New("scale").
+			Set("w", fmt.Sprintf("min(%d,iw)", enc.MaxWidth)).
+			Set("h", fmt.Sprintf("min(%d,ih)", enc.MaxHeight)).
+			Set("force_original_aspect_ratio", "decrease").
+			Set("force_divisible_by", 2)
+	case enc.MaxWidth > 0:
+		return filtergraph.New("scale").Set("w", fmt.Sprintf("min(%d,iw)", enc.MaxWidth)).Set("h", -2)
+	case enc.MaxHeight > 0:
+		return filtergraph.New("scale").Set("w", -2).Set("h", fmt.Sprintf("min(%d,ih)", enc.MaxHeight))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildEncodingArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		enc  EncodingOptions
+		want []string
+	}{
+		{"default", EncodingOptions{}, []string{"-codec:a", "copy"}},
+		{
+			"crf",
+			EncodingOptions{VideoCodec: "libx264", CRF: 23, Preset: "slow", PixelFormat: "yuv420p", FastStart: true},
+			[]string{"-codec:v", "libx264", "-crf", "23", "-preset", "slow", "-pix_fmt", "yuv420p", "-codec:a", "copy", "-movflags", "+faststart"},
+		},
+		{
+			"bitrate",
+			EncodingOptions{VideoCodec: "libvpx-vp9", Bitrate: "2M", AudioCodec: "libopus", Container: "webm"},
+			[]string{"-codec:v", "libvpx-vp9", "-b:v", "2M", "-codec:a", "libopus", "-f", "webm"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := buildEncodingArgs(tt.enc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: 得到 %v, 期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildMaxResolutionFilter(t *testing.T) {
+	req := ProcessRequest{
+		WatermarkLayer: WatermarkLayer{WatermarkPath: "wm.png", Scale: 100},
+		Encoding:       EncodingOptions{MaxWidth: 1280},
+	}
+
+	graph := buildOverlayFilter(req)
+	if !strings.HasSuffix(graph, ",scale=w='min(1280,iw)':h=-2") {
+		t.Errorf("未在最终输出上添加缩放滤镜: %s", graph)
+	}
+}
+
+func TestValidateEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		enc     EncodingOptions
+		wantErr bool
+	}{
+		{"empty", EncodingOptions{}, false},
+		{"crf", EncodingOptions{CRF: 28}, false},
+		{"bitrate", EncodingOptions{Bitrate: "2500k"}, false},
+		{"crf and bitrate", EncodingOptions{CRF: 20, Bitrate: "2M"}, true},
+		{"crf out of range", EncodingOptions{CRF: 64}, true},
+		{"bad bitrate", EncodingOptions{Bitrate: "fast"}, true},
+		{"negative max width", EncodingOptions{MaxWidth: -1}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateEncoding(tt.enc); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr = %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"ffwatermark/filtergraph"
+)
+
+// EXIF 方向标签
+const exifOrientationTag = 0x0112
+
+// readExifOrientation 读取 JPEG 文件的 EXIF 方向 (1-8), 没有方向信息时返回 1
+func readExifOrientation(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 1, err
+	}
+	defer file.Close()
+
+	return parseExifOrientation(bufio.NewReader(file))
+}
+
+// parseExifOrientation 从 JPEG 数据流中解析 EXIF 方向
+func parseExifOrientation(r io.Reader) (int, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil {
+		return 1, err
+	}
+	if soi[0] != 0xFF || soi[1] != 0xD8 {
+		return 1, fmt.Errorf("not a jpeg file")
+	}
+
+	// 遍历 JPEG 段, 查找 APP1 (Exif)
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return 1, err
+		}
+		if header[0] != 0xFF {
+			return 1, fmt.Errorf("invalid jpeg marker")
+		}
+
+		marker := header[1]
+		size := int(binary.BigEndian.Uint16(header[2:])) - 2
+		if size < 0 {
+			return 1, fmt.Errorf("invalid jpeg segment size")
+		}
+
+		// 图像数据开始 (SOS) 后不再有 EXIF
+		if marker == 0xDA {
+			return 1, nil
+		}
+
+		segment := make([]byte, size)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return 1, err
+		}
+
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return parseTIFFOrientation(segment[6:])
+		}
+	}
+}
+
+// parseTIFFOrientation 从 TIFF 结构的 IFD0 中读取方向标签
+func parseTIFFOrientation(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 1, fmt.Errorf("invalid exif data")
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1, fmt.Errorf("invalid exif byte order")
+	}
+
+	offset := int(order.Uint32(data[4:]))
+	if offset+2 > len(data) {
+		return 1, fmt.Errorf("invalid exif ifd offset")
+	}
+
+	count := int(order.Uint16(data[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(data) {
+			break
+		}
+		if order.Uint16(data[entry:]) == exifOrientationTag {
+			orientation := int(order.Uint16(data[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1, nil
+			}
+			return orientation, nil
+		}
+	}
+
+	return 1, nil
+}
+
+// orientationFilter 返回将图像按 EXIF 方向摆正所需的滤镜, 无需处理时返回空
+func orientationFilter(orientation int) []*filtergraph.Filter {
+	switch orientation {
+	case 2:
+		return []*filtergraph.Filter{filtergraph.New("hflip")}
+	case 3:
+		return []*filtergraph.Filter{filtergraph.New("hflip"), filtergraph.New("vflip")}
+	case 4:
+		return []*filtergraph.Filter{filtergraph.New("vflip")}
+	case 5:
+		return []*filtergraph.Filter{filtergraph.New("transpose", "0")}
+	case 6:
+		return []*filtergraph.Filter{filtergraph.New("transpose", "1")}
+	case 7:
+		return []*filtergraph.Filter{filtergraph.New("transpose", "3")}
+	case 8:
+		return []*filtergraph.Filter{filtergraph.New("transpose", "2")}
+	}
+	return nil
+}
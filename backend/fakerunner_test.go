@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeScript 假进程的预设行为
+type fakeScript struct {
+	match    string // 命令行中包含该子串时使用此脚本 (e.g., "format=duration")
+	stdout   string // 输出到 stdout 的内容
+	stderr   string // 输出到 stderr 的内容
+	exitCode int    // 退出码
+	block    bool   // 输出后阻塞, 直到进程被结束
+}
+
+// fakeRunner 可编程的 Runner, 按预设脚本模拟 FFmpeg/ffprobe, 并记录执行过的命令
+type fakeRunner struct {
+	scripts  []fakeScript
+	mutex    sync.Mutex
+	commands []string
+}
+
+// useFakeRunner 在测试期间用 fakeRunner 替换全局 runner
+func useFakeRunner(t *testing.T, scripts ...fakeScript) *fakeRunner {
+	r := &fakeRunner{scripts: scripts}
+	saved := runner
+	runner = r
+	t.Cleanup(func() { runner = saved })
+	return r
+}
+
+// Command 创建假进程, 使用第一个匹配的脚本; 没有匹配的脚本时进程以退出码 1 结束
+func (r *fakeRunner) Command(name string, args ...string) Process {
+	cmdline := strings.Join(append([]string{name}, args...), " ")
+
+	r.mutex.Lock()
+	r.commands = append(r.commands, cmdline)
+	r.mutex.Unlock()
+
+	script := fakeScript{stderr: "fake runner: no script for " + cmdline + "\n", exitCode: 1}
+	for _, s := range r.scripts {
+		if strings.Contains(cmdline, s.match) {
+			script = s
+			break
+		}
+	}
+
+	return &fakeProcess{cmdline: cmdline, script: script, killed: make(chan struct{}), done: make(chan struct{})}
+}
+
+// Commands 返回执行过的命令行
+func (r *fakeRunner) Commands() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.commands...)
+}
+
+// fakeProcess 按脚本输出内容并退出的假进程
+type fakeProcess struct {
+	cmdline string
+	script  fakeScript
+
+	stdout    io.WriteCloser
+	stderr    io.Writer
+	stderrEnd io.Closer
+
+	mutex    sync.Mutex
+	started  bool
+	killOnce sync.Once
+	killed   chan struct{}
+	done     chan struct{}
+	err      error
+}
+
+func (p *fakeProcess) StdinPipe() (io.WriteCloser, error) {
+	return nopWriteCloser{io.Discard}, nil
+}
+
+func (p *fakeProcess) StdoutPipe() (io.ReadCloser, error) {
+	r, w := io.Pipe()
+	p.stdout = w
+	return r, nil
+}
+
+func (p *fakeProcess) StderrPipe() (io.ReadCloser, error) {
+	r, w := io.Pipe()
+	p.stderr, p.stderrEnd = w, w
+	return r, nil
+}
+
+func (p *fakeProcess) SetStderr(w io.Writer) {
+	p.stderr = w
+}
+
+// Start 在协程中依次输出 stdout 和 stderr, 然后按脚本阻塞或退出
+func (p *fakeProcess) Start() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.started {
+		return errors.New("fake process already started")
+	}
+	p.started = true
+
+	go func() {
+		defer close(p.done)
+
+		if p.stdout != nil {
+			io.WriteString(p.stdout, p.script.stdout)
+			p.stdout.Close()
+		}
+		if p.stderr != nil {
+			io.WriteString(p.stderr, p.script.stderr)
+		}
+		if p.stderrEnd != nil {
+			p.stderrEnd.Close()
+		}
+
+		if p.script.block {
+			<-p.killed
+		}
+
+		select {
+		case <-p.killed:
+			p.err = errors.New("signal: killed")
+		default:
+			if p.script.exitCode != 0 {
+				p.err = fmt.Errorf("exit status %d", p.script.exitCode)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (p *fakeProcess) Wait() error {
+	p.mutex.Lock()
+	started := p.started
+	p.mutex.Unlock()
+	if !started {
+		return errors.New("fake process not started")
+	}
+	<-p.done
+	return p.err
+}
+
+func (p *fakeProcess) Run() error {
+	if err := p.Start(); err != nil {
+		return err
+	}
+	return p.Wait()
+}
+
+func (p *fakeProcess) Output() ([]byte, error) {
+	var stdout bytes.Buffer
+	r, err := p.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Start(); err != nil {
+		return nil, err
+	}
+	io.Copy(&stdout, r)
+	return stdout.Bytes(), p.Wait()
+}
+
+func (p *fakeProcess) Kill() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.started {
+		p.killOnce.Do(func() { close(p.killed) })
+	}
+	return nil
+}
+
+func (p *fakeProcess) String() string {
+	return p.cmdline
+}
+
+// nopWriteCloser 为 io.Writer 添加空的 Close 方法
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// fakeProgress 生成 FFmpeg -progress 输出, 每个时间点 (秒) 一个进度块, 最后一块以 progress=end 结尾
+func fakeProgress(times ...float64) string {
+	var b strings.Builder
+	for i, seconds := range times {
+		fmt.Fprintf(&b, "frame=%d\nfps=25.00\nbitrate=1024.0kbits/s\ntotal_size=%d\n", int(seconds*25), int(seconds*128000))
+		fmt.Fprintf(&b, "out_time=00:00:%09.6f\nspeed=2.0x\n", seconds)
+		if i == len(times)-1 {
+			b.WriteString("progress=end\n")
+		} else {
+			b.WriteString("progress=continue\n")
+		}
+	}
+	return b.String()
+}
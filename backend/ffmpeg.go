@@ -5,24 +5,30 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"os/exec"
-	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"ffwatermark/filtergraph"
 )
 
 // FFmpegTask FFmpeg 任务结构
 type FFmpegTask struct {
 	ID           string
-	Cmd          *exec.Cmd
+	Cmd          Process
 	Status       *TaskStatus
 	StdoutPipe   io.ReadCloser
 	StderrPipe   io.ReadCloser
 	ProgressChan chan int
 	DoneChan     chan bool
 	Mutex        sync.Mutex
+	Pipeline     *framePipeline // 不可见水印模式下的解码和嵌入流程, Cmd 为其编码进程
+
+	outputDone chan struct{} // stdout 和 stderr 读取完毕时关闭
+	tempFiles  []string      // 任务结束时删除的临时文件 (e.g., 平铺图层)
+	queue      *TaskQueue    // 任务所在的队列, 未经队列启动时为 nil
+	stopped    bool          // 是否已被用户停止, 停止后不再启动
 }
 
 // NewFFmpegTask 创建新的 FFmpeg 任务
@@ -30,9 +36,43 @@ func NewFFmpegTask(req ProcessRequest) (*FFmpegTask, error) {
 	// 生成任务ID
 	taskID := fmt.Sprintf("task_%d", time.Now().UnixNano())
 
-	// 构建 FFmpeg 命令
-	args := buildFFmpegArgs(req)
-	cmd := exec.Command(AppConfig.FFmpegPath, args...)
+	// 获取源文件时长, 用于计算进度百分比 (静态图片没有时长)
+	var duration float64
+	if !isStillImage(req.SourcePath) {
+		var err error
+		if duration, err = probeDuration(req.SourcePath); err != nil {
+			slog.Warn("无法获取媒体时长, 进度将不可用", "path", req.SourcePath, "error", err)
+		}
+	}
+
+	// 预处理水印层: 转换显示时间, 生成平铺图层
+	tempFiles, err := prepareLayers(&req, duration, true)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := newFFmpegTask(taskID, req, duration)
+	if err != nil {
+		removeTempFiles(tempFiles)
+		return nil, err
+	}
+	task.tempFiles = tempFiles
+	return task, nil
+}
+
+// newFFmpegTask 为预处理后的请求创建进程和任务状态
+func newFFmpegTask(taskID string, req ProcessRequest, duration float64) (*FFmpegTask, error) {
+	// 构建 FFmpeg 命令, 不可见水印需要先解码, 在 Go 中逐帧嵌入后再编码
+	var cmd Process
+	var pipeline *framePipeline
+	if layer, ok := invisibleLayer(req); ok {
+		var err error
+		if pipeline, cmd, err = newFramePipeline(req, layer); err != nil {
+			return nil, err
+		}
+	} else {
+		cmd = runner.Command(AppConfig.FFmpegPath, buildFFmpegArgs(req)...)
+	}
 
 	// 设置管道
 	stdoutPipe, err := cmd.StdoutPipe()
@@ -50,6 +90,7 @@ func NewFFmpegTask(req ProcessRequest) (*FFmpegTask, error) {
 		ID:        taskID,
 		Status:    "pending",
 		Progress:  0,
+		Duration:  duration,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -65,24 +106,48 @@ func NewFFmpegTask(req ProcessRequest) (*FFmpegTask, error) {
 		StderrPipe:   stderrPipe,
 		ProgressChan: make(chan int),
 		DoneChan:     make(chan bool),
+		Pipeline:     pipeline,
+		outputDone:   make(chan struct{}),
 	}, nil
 }
 
 // buildFFmpegArgs 构建 FFmpeg 命令参数
 func buildFFmpegArgs(req ProcessRequest) []string {
+	still := isStillImage(req.SourcePath)
+
 	// 基础参数
 	args := []string{
-		"-i", req.SourcePath, // 输入文件
-		"-i", req.WatermarkPath, // 水印图片
+		"-progress", "pipe:1", // 将结构化进度信息输出到 stdout
+		"-nostats", // 关闭 stderr 中的进度统计
+	}
+
+	// 静态图片的方向由 sourceFilter 按 EXIF 处理, 关闭 FFmpeg 自动旋转避免重复处理
+	if still {
+		args = append(args, "-noautorotate")
+	}
+	args = append(args, "-i", req.SourcePath) // 输入文件
+
+	// 每个水印层一个输入
+	for i, layer := range req.watermarkLayers() {
+		args = append(args, watermarkInputArgs(layer)...)
+		args = append(args, "-i", layer.WatermarkPath)
+		slog.Info("水印位置设置", "layer", i+1, "position", layer.Position, "offsetX", layer.OffsetX, "offsetY", layer.OffsetY, "motion", layer.Motion.Type)
 	}
 
 	// 设置水印位置和大小
 	overlay := buildOverlayFilter(req)
 
 	// 添加滤镜参数
+	args = append(args, "-filter_complex", overlay)
+
+	// 添加编码参数, 静态图片只输出单帧
+	if still {
+		args = append(args, buildImageArgs(req.Image, req.OutputPath)...)
+	} else {
+		args = append(args, buildEncodingArgs(req.Encoding)...)
+	}
+
 	args = append(args,
-		"-filter_complex", overlay,
-		"-codec:a", "copy", // 复制音频流
 		"-y", // 覆盖输出文件
 		req.OutputPath,
 	)
@@ -92,91 +157,250 @@ func buildFFmpegArgs(req ProcessRequest) []string {
 	return args
 }
 
+// formatCommand 将命令和参数拼接为 shell 命令字符串, 含有特殊字符的参数按 POSIX shell 规则加单引号
+func formatCommand(name string, args []string) string {
+	quoted := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{name}, args...) {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote 为 shell 参数加引号, 只含安全字符的参数原样返回; 参数中的单引号写作 '\''
+func shellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	safe := strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=+,@%", r))
+	}) < 0
+	if safe {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
 // buildOverlayFilter 构建水印叠加滤镜参数
 func buildOverlayFilter(req ProcessRequest) string {
-	// 计算水印位置
-	position := "x=0:y=0" // 默认左上角
-	switch req.Position {
-	case "center":
-		position = "x=(main_w-overlay_w)/2:y=(main_h-overlay_h)/2"
-	case "top-right":
-		position = "x=main_w-overlay_w:y=0"
-	case "bottom-left":
-		position = "x=0:y=main_h-overlay_h"
-	case "bottom-right":
-		position = "x=main_w-overlay_w:y=main_h-overlay_h"
-	}
-
-	slog.Info("水印位置设置", "position", position)
-
-	// 构建完整的滤镜字符串
-	return fmt.Sprintf(
-		"[1]scale=iw*%d/100:-1[watermark];[0][watermark]overlay=%s:alpha=%d/100",
-		req.Scale,
-		position,
-		req.Opacity,
-	)
+	return buildFilterGraph(req).String()
+}
+
+// buildFilterGraph 构建水印叠加滤镜图
+//
+// 多个水印层依次叠加: 第 i 个水印层对应第 i 个水印输入, 叠加在上一层的输出之上
+func buildFilterGraph(req ProcessRequest) *filtergraph.Graph {
+	layers := req.watermarkLayers()
+	var g filtergraph.Graph
+
+	main := "0"
+
+	// 源画面预处理 (e.g., 按 EXIF 方向摆正图片)
+	if filters := sourceFilter(req.SourcePath); len(filters) > 0 {
+		g.Chain("0").Then(filters...).To("src")
+		main = "src"
+	}
+
+	// 去除已有的水印等区域, 在叠加新水印之前完成
+	if len(req.Regions) > 0 {
+		g.Extend(buildRegionChains(req.Regions, main, "clean"))
+		main = "clean"
+	}
+
+	for i, layer := range layers {
+		input := i + 1
+
+		// 最后一层的输出不加标签, 作为最终视频输出
+		out := ""
+		if input < len(layers) {
+			out = fmt.Sprintf("v%d", input)
+		}
+
+		g.Extend(buildLayerFilter(layer, input, main, out))
+		main = out
+	}
+
+	// 在最终输出上限制最大分辨率
+	g.Last().Then(buildMaxResolutionFilter(req.Encoding))
+
+	return &g
+}
+
+// buildLayerFilter 构建单个水印层的叠加滤镜
+func buildLayerFilter(layer WatermarkLayer, input int, main, out string) *filtergraph.Graph {
+	var g filtergraph.Graph
+	wm := strconv.Itoa(input)
+	label := func(name string) string {
+		return fmt.Sprintf("%s%d", name, input)
+	}
+
+	// 水印预处理: 动态水印的时间戳从 0 开始, 与主画面对齐; overlay 按时间戳为每一帧主画面选取对应的水印帧
+	var filters []*filtergraph.Filter
+	if isAnimatedWatermark(layer.WatermarkPath) {
+		filters = append(filters, filtergraph.New("setpts", "PTS-STARTPTS"))
+	}
+
+	// 透明度和透明度效果作用于水印的透明通道
+	alpha := append(opacityFilters(layer.Opacity), effectFilters(layer)...)
+	if len(alpha) > 0 {
+		filters = append(filters, filtergraph.New("format", "rgba"))
+		filters = append(filters, alpha...)
+	}
+
+	switch {
+	case layer.Mode == "tile":
+		// 平铺模式的水印图层已与画面等大, 不需要缩放
+		if len(filters) > 0 {
+			g.Chain(wm).Then(filters...).To(label("wm"))
+			wm = label("wm")
+		}
+	case isRelativeSize(layer):
+		// 相对主画面缩放: scale2ref 以主画面为参考缩放水印, 并原样输出主画面供 overlay 使用
+		if len(filters) > 0 {
+			g.Chain(wm).Then(filters...).To(label("wmsrc"))
+			wm = label("wmsrc")
+		}
+		transform := transformFilters(layer)
+		if len(transform) > 0 {
+			// 旋转和透视变换在缩放之后进行, 以免变换后的外接矩形影响相对尺寸
+			g.Chain(wm, main).Then(scale2refFilter(layer)).To(label("wmscaled"), label("ref"))
+			g.Chain(label("wmscaled")).Then(transform...).To(label("wm"))
+		} else {
+			g.Chain(wm, main).Then(scale2refFilter(layer)).To(label("wm"), label("ref"))
+		}
+		wm = label("wm")
+		main = label("ref")
+	default:
+		filters = append(filters, filtergraph.New("scale", fmt.Sprintf("iw*%d/100", layer.Scale), "-1"))
+		filters = append(filters, transformFilters(layer)...)
+		g.Chain(wm).Then(filters...).To(label("wm"))
+		wm = label("wm")
+	}
+
+	// 计算水印位置, 平铺图层从左上角叠加, 运动水印使用随时间变化的表达式
+	x, y := "0", "0"
+	if layer.Mode != "tile" {
+		x, y = buildPositionExpr(layer)
+		if mx, my, ok := buildMotionExpr(layer); ok {
+			x, y = mx, my
+		}
+	}
+
+	// 构建叠加滤镜
+	overlay := filtergraph.New("overlay").Set("x", x).Set("y", y)
+	playbackOption(overlay, layer)
+	if expr := buildEnableExpr(layer.Visibility); expr != "" {
+		overlay.Set("enable", expr)
+	}
+
+	if isBlendMode(layer.Blend) {
+		g.Extend(buildBlendChains(layer.Blend, input, main, wm, overlay, out))
+	} else {
+		chain := g.Chain(main, wm).Then(overlay)
+		if out != "" {
+			chain.To(out)
+		}
+	}
+
+	return &g
 }
 
 // Start 启动 FFmpeg 任务
 func (t *FFmpegTask) Start() error {
-	// 更新任务状态
-	t.Status.Status = "processing"
-	t.Status.UpdatedAt = time.Now()
-
 	// 启动进度监控
 	go t.monitorProgress()
 
 	// 启动命令
 	if err := t.Cmd.Start(); err != nil {
-		t.Status.Status = "failed"
-		t.Status.Error = err.Error()
-		t.Status.UpdatedAt = time.Now()
+		t.fail(err)
 		return fmt.Errorf("failed to start ffmpeg: %v", err)
 	}
 
+	// 不可见水印: 编码进程就绪后启动解码进程
+	if t.Pipeline != nil {
+		if err := t.Pipeline.start(); err != nil {
+			t.Cmd.Kill()
+			t.fail(err)
+			return fmt.Errorf("failed to start decoder: %v", err)
+		}
+	}
+
+	// 更新任务状态; 启动过程中被停止的任务立即结束进程
+	t.Mutex.Lock()
+	if t.stopped {
+		t.Cmd.Kill()
+		if t.Pipeline != nil {
+			t.Pipeline.kill()
+		}
+	} else {
+		t.Status.Status = "processing"
+		t.Status.UpdatedAt = time.Now()
+	}
+	t.Mutex.Unlock()
+
 	slog.Info("FFmpeg任务启动", "taskID", t.ID)
 
 	// 等待命令完成
 	go func() {
+		// 读完 stdout 和 stderr 后再等待进程退出, 避免丢失最后的进度信息
+		<-t.outputDone
 		err := t.Cmd.Wait()
+		if t.Pipeline != nil {
+			err = t.Pipeline.wait(err)
+		}
 		t.Mutex.Lock()
 		defer t.Mutex.Unlock()
 
 		if err != nil {
-			t.Status.Status = "failed"
-			t.Status.Error = err.Error()
+			// 被用户停止的任务保留停止原因, 不用 "signal: killed" 覆盖
+			if t.Status.Status != "failed" {
+				t.Status.Status = "failed"
+				t.Status.Error = err.Error()
+			}
 		} else {
 			t.Status.Status = "completed"
 			t.Status.Progress = 100
 		}
 
 		t.Status.UpdatedAt = time.Now()
-		close(t.DoneChan)
+		t.finish()
 	}()
 
 	return nil
 }
 
+// fail 将启动失败的任务标记为失败, 并关闭 DoneChan 通知等待者
+func (t *FFmpegTask) fail(err error) {
+	t.Mutex.Lock()
+	t.Status.Status = "failed"
+	t.Status.Error = err.Error()
+	t.Status.UpdatedAt = time.Now()
+	t.Mutex.Unlock()
+
+	t.finish()
+}
+
+// finish 删除任务的临时文件, 并关闭 DoneChan 通知等待者; 每个任务只调用一次
+func (t *FFmpegTask) finish() {
+	removeTempFiles(t.tempFiles)
+	close(t.DoneChan)
+}
+
 // monitorProgress 监控 FFmpeg 进度
 func (t *FFmpegTask) monitorProgress() {
-	// 创建 stdout 监控协程
+	defer close(t.outputDone)
+
+	// 创建 stdout 监控协程, 解析 -progress 输出的 key=value 进度信息
+	stdoutDone := make(chan struct{})
 	go func() {
+		defer close(stdoutDone)
 		scanner := bufio.NewScanner(t.StdoutPipe)
 		for scanner.Scan() {
-			line := scanner.Text()
-			t.Mutex.Lock()
-			t.Status.Output = append(t.Status.Output, "[stdout] "+line)
-			t.Status.UpdatedAt = time.Now()
-			t.Mutex.Unlock()
-			fmt.Println("[FFmpeg stdout] ", line)
+			t.handleProgressLine(scanner.Text())
 		}
 	}()
 
 	// 监控 stderr 输出
 	scanner := bufio.NewScanner(t.StderrPipe)
-	progressRegex := regexp.MustCompile(`time=([0-9:.]+)`) // 匹配时间信息
-
 	for scanner.Scan() {
 		line := scanner.Text()
 
@@ -184,22 +408,56 @@ func (t *FFmpegTask) monitorProgress() {
 		t.Mutex.Lock()
 		t.Status.Output = append(t.Status.Output, "[stderr] "+line)
 		t.Status.UpdatedAt = time.Now()
+		t.Mutex.Unlock()
 
 		fmt.Println("[FFmpeg stderr] ", line)
+	}
 
-		// 解析进度信息
-		if matches := progressRegex.FindStringSubmatch(line); len(matches) > 1 {
-			timeStr := matches[1]
-			seconds := parseFFmpegTime(timeStr)
-			t.Status.Progress = int(seconds) // 这里需要根据视频总长度计算百分比
+	<-stdoutDone
+}
 
-			// 发送进度更新
-			select {
-			case t.ProgressChan <- t.Status.Progress:
-			default:
-			}
+// handleProgressLine 解析一行 -progress 输出并更新任务状态
+//
+// FFmpeg 每个进度块由若干 key=value 行组成, 以 progress=continue 或 progress=end 结尾
+func (t *FFmpegTask) handleProgressLine(line string) {
+	key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok {
+		return
+	}
+
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+
+	switch key {
+	case "frame":
+		t.Status.Frame, _ = strconv.ParseInt(value, 10, 64)
+	case "fps":
+		t.Status.FPS, _ = strconv.ParseFloat(value, 64)
+	case "bitrate":
+		t.Status.Bitrate = value
+	case "total_size":
+		t.Status.TotalSize, _ = strconv.ParseInt(value, 10, 64)
+	case "out_time":
+		t.Status.OutTime = value
+		if seconds := parseFFmpegTime(value); seconds >= 0 {
+			t.Status.CurrentTime = seconds
+		}
+	case "speed":
+		t.Status.Speed, _ = strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+	case "progress":
+		// 一个进度块结束, 计算百分比和剩余时间
+		t.Status.Progress = calcProgress(t.Status.CurrentTime, t.Status.Duration)
+		t.Status.ETA = calcETA(t.Status.CurrentTime, t.Status.Duration, t.Status.Speed)
+		if value == "end" {
+			t.Status.ETA = 0
+		}
+		t.Status.UpdatedAt = time.Now()
+
+		// 发送进度更新
+		select {
+		case t.ProgressChan <- t.Status.Progress:
+		default:
 		}
-		t.Mutex.Unlock()
 	}
 }
 
@@ -217,6 +475,30 @@ func parseFFmpegTime(timeStr string) float64 {
 	return hours*3600 + minutes*60 + seconds
 }
 
+// calcProgress 根据已处理时长和总时长计算进度百分比 (0-100)
+func calcProgress(current, duration float64) int {
+	if duration <= 0 {
+		return 0
+	}
+
+	progress := int(current / duration * 100)
+	if progress < 0 {
+		return 0
+	}
+	if progress > 100 {
+		return 100
+	}
+	return progress
+}
+
+// calcETA 根据处理速度估算剩余时间 (秒), 无法估算时返回 0
+func calcETA(current, duration, speed float64) float64 {
+	if duration <= 0 || speed <= 0 || current >= duration {
+		return 0
+	}
+	return (duration - current) / speed
+}
+
 // GetStatus 获取任务状态
 func (t *FFmpegTask) GetStatus() *TaskStatus {
 	t.Mutex.Lock()
@@ -226,20 +508,32 @@ func (t *FFmpegTask) GetStatus() *TaskStatus {
 
 // Stop 停止任务
 func (t *FFmpegTask) Stop() error {
-	if t.Cmd.Process == nil {
+	t.Mutex.Lock()
+	// 已经结束的任务保留原有状态
+	if t.Status.Status == "completed" || t.Status.Status == "failed" {
+		t.Mutex.Unlock()
 		return nil
 	}
-
-	// 发送终止信号
-	if err := t.Cmd.Process.Kill(); err != nil {
-		return fmt.Errorf("failed to kill process: %v", err)
-	}
-
-	t.Mutex.Lock()
+	t.stopped = true
 	t.Status.Status = "failed"
 	t.Status.Error = "Task stopped by user"
 	t.Status.UpdatedAt = time.Now()
+	queue := t.queue
 	t.Mutex.Unlock()
 
+	// 仍在排队的任务直接移出队列, 不再启动
+	if queue != nil && queue.Remove(t) {
+		t.finish()
+		return nil
+	}
+
+	// 发送终止信号
+	if err := t.Cmd.Kill(); err != nil {
+		return fmt.Errorf("failed to kill process: %v", err)
+	}
+	if t.Pipeline != nil {
+		t.Pipeline.kill()
+	}
+
 	return nil
 }
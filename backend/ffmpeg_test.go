@@ -1,36 +1,59 @@
 package main
 
 import (
-	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"ffwatermark/filtergraph"
 )
 
-func TestFFmpegTask(t *testing.T) {
-	// 加载配置文件
-	if err := LoadConfig(); err != nil {
-		t.Fatalf("加载配置文件失败: %v", err)
-	}
+// joinFilters 返回依次连接的滤镜描述
+func joinFilters(filters []*filtergraph.Filter) string {
+	return (&filtergraph.Chain{Filters: filters}).String()
+}
 
-	// 验证FFmpeg路径
-	if _, err := os.Stat(AppConfig.FFmpegPath); os.IsNotExist(err) {
-		t.Fatalf("FFmpeg可执行文件不存在: %s", AppConfig.FFmpegPath)
+// positionOptions 返回 overlay 位置参数的描述
+func positionOptions(x, y string) string {
+	return strings.TrimPrefix(filtergraph.New("overlay").Set("x", x).Set("y", y).String(), "overlay=")
+}
+
+// waitTask 等待任务结束并返回最终状态
+func waitTask(t *testing.T, task *FFmpegTask) TaskStatus {
+	t.Helper()
+	select {
+	case <-task.DoneChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("任务执行超时")
 	}
 
+	task.Mutex.Lock()
+	defer task.Mutex.Unlock()
+	return *task.Status
+}
+
+func TestFFmpegTask(t *testing.T) {
+	runner := useFakeRunner(t,
+		fakeScript{match: "format=duration", stdout: "10.000000\n"},
+		fakeScript{match: "-progress pipe:1", stdout: fakeProgress(2.5, 5, 10), stderr: "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n"},
+	)
+
 	// 准备测试文件路径
-	sourcePath := filepath.Join("../test_media", "bbb.mp4")
+	sourcePath := filepath.Join("../test_media", "in.mp4")
 	watermarkPath := filepath.Join("../test_media", "test_watermark.png")
 	outputPath := filepath.Join("../test_media", "output.mp4")
 
 	// 创建处理请求
 	req := ProcessRequest{
-		SourcePath:    sourcePath,
-		WatermarkPath: watermarkPath,
-		OutputPath:    outputPath,
-		Position:      "center", // 测试中心位置
-		Scale:         50,       // 水印大小为原始大小的50%
-		Opacity:       80,       // 不透明度80%
+		SourcePath: sourcePath,
+		OutputPath: outputPath,
+		WatermarkLayer: WatermarkLayer{
+			WatermarkPath: watermarkPath,
+			Position:      "center", // 测试中心位置
+			Scale:         50,       // 水印大小为原始大小的50%
+			Opacity:       80,       // 不透明度80%
+		},
 	}
 
 	// 创建FFmpeg任务
@@ -43,42 +66,302 @@ func TestFFmpegTask(t *testing.T) {
 	if task.Status.Status != "pending" {
 		t.Errorf("初始状态错误: 期望 'pending', 得到 %s", task.Status.Status)
 	}
+	if task.Status.Duration != 10 {
+		t.Errorf("时长错误: 期望 10, 得到 %v", task.Status.Duration)
+	}
 
 	// 启动任务
 	if err := task.Start(); err != nil {
 		t.Fatalf("启动任务失败: %v", err)
 	}
 
-	// 监控任务进度
-	progressUpdated := false
-	timeout := time.After(30 * time.Second)
+	// 验证任务完成状态和最后一个进度块
+	status := waitTask(t, task)
+	if status.Status != "completed" || status.Progress != 100 {
+		t.Errorf("任务未成功完成，最终状态: %s, 进度: %d, 错误: %s", status.Status, status.Progress, status.Error)
+	}
+	if status.Frame != 250 || status.CurrentTime != 10 || status.Speed != 2 || status.ETA != 0 {
+		t.Errorf("进度信息错误: %+v", status)
+	}
+	if len(status.Output) != 1 || !strings.Contains(status.Output[0], "Input #0") {
+		t.Errorf("stderr 输出错误: %q", status.Output)
+	}
+
+	// 验证执行的命令: ffprobe 获取时长, 然后运行 FFmpeg
+	commands := runner.Commands()
+	if len(commands) != 2 || !strings.HasSuffix(commands[1], "-y "+outputPath) {
+		t.Errorf("执行的命令错误: %q", commands)
+	}
+}
+
+func TestFFmpegTaskFailure(t *testing.T) {
+	useFakeRunner(t,
+		fakeScript{match: "format=duration", stdout: "10.000000\n"},
+		fakeScript{match: "-progress pipe:1", stdout: fakeProgress(1), stderr: "Unknown encoder 'libfoo'\n", exitCode: 1},
+	)
+
+	req := ProcessRequest{
+		SourcePath:     "in.mp4",
+		OutputPath:     "out.mp4",
+		WatermarkLayer: WatermarkLayer{WatermarkPath: "logo.png", Scale: 100, Opacity: 100},
+	}
+	task, err := NewFFmpegTask(req)
+	if err != nil {
+		t.Fatalf("创建FFmpeg任务失败: %v", err)
+	}
+	if err := task.Start(); err != nil {
+		t.Fatalf("启动任务失败: %v", err)
+	}
+
+	status := waitTask(t, task)
+	if status.Status != "failed" || status.Error != "exit status 1" {
+		t.Errorf("最终状态: %s, 错误: %s, 期望 failed, exit status 1", status.Status, status.Error)
+	}
+	if status.Progress != 10 {
+		t.Errorf("失败前的进度: 得到 %d, 期望 10", status.Progress)
+	}
+	if len(status.Output) != 1 || status.Output[0] != "[stderr] Unknown encoder 'libfoo'" {
+		t.Errorf("stderr 输出错误: %q", status.Output)
+	}
+}
+
+func TestFFmpegTaskStop(t *testing.T) {
+	useFakeRunner(t,
+		fakeScript{match: "format=duration", stdout: "10.000000\n"},
+		fakeScript{match: "-progress pipe:1", stdout: fakeProgress(1), block: true},
+	)
+
+	req := ProcessRequest{
+		SourcePath:     "in.mp4",
+		OutputPath:     "out.mp4",
+		WatermarkLayer: WatermarkLayer{WatermarkPath: "logo.png", Scale: 100, Opacity: 100},
+	}
+	task, err := NewFFmpegTask(req)
+	if err != nil {
+		t.Fatalf("创建FFmpeg任务失败: %v", err)
+	}
+	if err := task.Start(); err != nil {
+		t.Fatalf("启动任务失败: %v", err)
+	}
+
+	select {
+	case <-task.DoneChan:
+		t.Fatal("任务在停止之前结束")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := task.Stop(); err != nil {
+		t.Fatalf("停止任务失败: %v", err)
+	}
+
+	// 停止原因不被进程退出的错误覆盖
+	status := waitTask(t, task)
+	if status.Status != "failed" || status.Error != "Task stopped by user" {
+		t.Errorf("最终状态: %s, 错误: %s, 期望 failed, Task stopped by user", status.Status, status.Error)
+	}
+}
+
+func TestFFmpegTaskStopFinished(t *testing.T) {
+	useFakeRunner(t,
+		fakeScript{match: "format=duration", stdout: "10.000000\n"},
+		fakeScript{match: "-progress pipe:1", stdout: fakeProgress(5, 10)},
+	)
+
+	req := ProcessRequest{
+		SourcePath:     "in.mp4",
+		OutputPath:     "out.mp4",
+		WatermarkLayer: WatermarkLayer{WatermarkPath: "logo.png", Scale: 100, Opacity: 100},
+	}
+	task, err := NewFFmpegTask(req)
+	if err != nil {
+		t.Fatalf("创建FFmpeg任务失败: %v", err)
+	}
+	if err := task.Start(); err != nil {
+		t.Fatalf("启动任务失败: %v", err)
+	}
+	waitTask(t, task)
+
+	// 停止已经完成的任务不改变其状态
+	if err := task.Stop(); err != nil {
+		t.Errorf("停止已完成的任务: 错误 %v", err)
+	}
+	if status := task.GetStatus(); status.Status != "completed" || status.Error != "" {
+		t.Errorf("停止已完成的任务后: 状态 %s, 错误 %q, 期望 completed", status.Status, status.Error)
+	}
+}
+
+func TestFormatCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-i", "in.mp4", "-y", "out/a-1.mp4"}, "ffmpeg -i in.mp4 -y out/a-1.mp4"},
+		{[]string{"-i", "my clip.mp4"}, "ffmpeg -i 'my clip.mp4'"},
+		{[]string{"-filter_complex", "[0][1]overlay=enable='between(t,0,10)'[v1]"}, `ffmpeg -filter_complex '[0][1]overlay=enable='\''between(t,0,10)'\''[v1]'`},
+		{[]string{"-vf", "scale=iw/2:-1;$HOME"}, `ffmpeg -vf 'scale=iw/2:-1;$HOME'`},
+		{[]string{"-metadata", ""}, "ffmpeg -metadata ''"},
+	}
 
-	for {
-		select {
-		case progress := <-task.ProgressChan:
-			progressUpdated = true
-			t.Logf("当前进度: %d", progress)
-		case <-task.DoneChan:
-			goto done
-		case <-timeout:
-			t.Fatal("任务执行超时")
+	for _, tt := range tests {
+		if got := formatCommand("ffmpeg", tt.args); got != tt.want {
+			t.Errorf("%q: 得到 %s, 期望 %s", tt.args, got, tt.want)
 		}
 	}
+}
 
-done:
-	// 验证任务完成状态
-	finalStatus := task.GetStatus()
-	if finalStatus.Status != "completed" {
-		t.Errorf("任务未成功完成，最终状态: %s, 错误: %s", finalStatus.Status, finalStatus.Error)
+func TestCalcProgress(t *testing.T) {
+	tests := []struct {
+		current  float64
+		duration float64
+		want     int
+	}{
+		{0, 600, 0},
+		{540, 600, 90},
+		{600, 600, 100},
+		{610, 600, 100}, // 超出总时长时截断
+		{30, 0, 0},      // 时长未知
 	}
 
-	// 验证进度更新是否正常工作
-	if !progressUpdated {
+	for _, tt := range tests {
+		if got := calcProgress(tt.current, tt.duration); got != tt.want {
+			t.Errorf("calcProgress(%v, %v) = %d, 期望 %d", tt.current, tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestHandleProgressLine(t *testing.T) {
+	task := &FFmpegTask{
+		Status:       &TaskStatus{Duration: 600},
+		ProgressChan: make(chan int, 1),
+	}
+
+	block := []string{
+		"frame=1500",
+		"fps=48.50",
+		"bitrate=1024.5kbits/s",
+		"total_size=7864320",
+		"out_time=00:05:00.000000",
+		"speed=2.00x",
+		"progress=continue",
+	}
+	for _, line := range block {
+		task.handleProgressLine(line)
+	}
+
+	status := task.GetStatus()
+	if status.Frame != 1500 || status.FPS != 48.5 || status.Bitrate != "1024.5kbits/s" || status.TotalSize != 7864320 {
+		t.Errorf("进度字段解析错误: %+v", status)
+	}
+	if status.CurrentTime != 300 || status.Progress != 50 {
+		t.Errorf("进度错误: currentTime=%v, progress=%d", status.CurrentTime, status.Progress)
+	}
+	if status.Speed != 2 || status.ETA != 150 {
+		t.Errorf("剩余时间错误: speed=%v, eta=%v", status.Speed, status.ETA)
+	}
+
+	select {
+	case progress := <-task.ProgressChan:
+		if progress != 50 {
+			t.Errorf("进度更新错误: 期望 50, 得到 %d", progress)
+		}
+	default:
 		t.Error("未收到进度更新")
 	}
+}
+
+func TestBuildOverlayFilter(t *testing.T) {
+	logo := WatermarkLayer{WatermarkPath: "logo.png", Position: "top-right", Scale: 50, Opacity: 80}
+	copyright := WatermarkLayer{
+		WatermarkPath: "copyright.png",
+		Position:      "bottom-left",
+		Scale:         100,
+		Opacity:       100,
+		Visibility:    VisibilityOptions{Intervals: []TimeRange{{Start: 0, End: 10}}},
+	}
+
+	tests := []struct {
+		name string
+		req  ProcessRequest
+		want string
+	}{
+		{
+			"single watermark",
+			ProcessRequest{WatermarkLayer: logo},
+			"[1]format=rgba,colorchannelmixer=aa=0.8,scale=iw*50/100:-1[wm1];[0][wm1]overlay=x=main_w-overlay_w:y=0",
+		},
+		{
+			"two layers",
+			ProcessRequest{Layers: []WatermarkLayer{logo, copyright}},
+			"[1]format=rgba,colorchannelmixer=aa=0.8,scale=iw*50/100:-1[wm1];[0][wm1]overlay=x=main_w-overlay_w:y=0[v1];" +
+				"[2]scale=iw*100/100:-1[wm2];[v1][wm2]overlay=x=0:y=main_h-overlay_h:enable='between(t,0,10)'",
+		},
+		{
+			"regions and max resolution",
+			ProcessRequest{
+				WatermarkLayer: logo,
+				Regions:        []RemovalRegion{{X: 0, Y: 0, Width: 200, Height: 60, Method: "pixelate", Strength: 10, End: 30}},
+				Encoding:       EncodingOptions{MaxWidth: 1280},
+			},
+			"[0]split[rbase1][rcrop1];" +
+				"[rcrop1]crop=200:60:0:0,scale=w='max(1,iw/10)':h='max(1,ih/10)',scale=200:60:flags=neighbor[rfill1];" +
+				"[rbase1][rfill1]overlay=x=0:y=0:enable='between(t,0,30)'[clean];" +
+				"[1]format=rgba,colorchannelmixer=aa=0.8,scale=iw*50/100:-1[wm1];" +
+				"[clean][wm1]overlay=x=main_w-overlay_w:y=0,scale=w='min(1280,iw)':h=-2",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := buildOverlayFilter(tt.req); got != tt.want {
+			t.Errorf("%s:\n得到 %q\n期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildFFmpegArgsLayers(t *testing.T) {
+	req := ProcessRequest{
+		SourcePath: "in.mp4",
+		OutputPath: "out.mp4",
+		Layers: []WatermarkLayer{
+			{WatermarkPath: "logo.png", Scale: 100},
+			{WatermarkPath: "copyright.png", Scale: 100},
+		},
+	}
 
-	// 验证输出文件是否生成
-	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
-		t.Error("输出文件未生成")
+	args := strings.Join(buildFFmpegArgs(req), " ")
+	if !strings.Contains(args, "-i in.mp4 -i logo.png -i copyright.png") {
+		t.Errorf("输入参数错误: %s", args)
+	}
+}
+
+func TestBuildFFmpegArgsAnimatedWatermark(t *testing.T) {
+	tests := []struct {
+		name      string
+		layer     WatermarkLayer
+		wantInput string
+		wantGraph string
+	}{
+		{
+			"looped gif",
+			WatermarkLayer{WatermarkPath: "sting.gif", Scale: 100, Opacity: 100},
+			"-an -stream_loop -1 -i sting.gif",
+			"[1]setpts=PTS-STARTPTS,scale=iw*100/100:-1[wm1];[0][wm1]overlay=x=0:y=0:shortest=1",
+		},
+		{
+			"webm played once",
+			WatermarkLayer{WatermarkPath: "sting.webm", Scale: 50, Opacity: 100, Playback: "once"},
+			"-an -codec:v libvpx-vp9 -i sting.webm",
+			"[1]setpts=PTS-STARTPTS,scale=iw*50/100:-1[wm1];[0][wm1]overlay=x=0:y=0:eof_action=pass",
+		},
+	}
+
+	for _, tt := range tests {
+		req := ProcessRequest{SourcePath: "in.mp4", OutputPath: "out.mp4", WatermarkLayer: tt.layer}
+		args := strings.Join(buildFFmpegArgs(req), " ")
+		if !strings.Contains(args, tt.wantInput) {
+			t.Errorf("%s: 输入参数错误: %s", tt.name, args)
+		}
+		if graph := buildOverlayFilter(req); graph != tt.wantGraph {
+			t.Errorf("%s:\n得到 %q\n期望 %q", tt.name, graph, tt.wantGraph)
+		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,164 @@
+// Package filtergraph 构建 FFmpeg 滤镜图 (-filter_complex / -vf) 字符串
+//
+// 滤镜图由若干滤镜链组成, 每条链有输入标签、依次连接的滤镜和输出标签.
+// 参数值会按 FFmpeg 的两级解析规则自动转义: 先按滤镜参数规则转义 \ ' :,
+// 再在含有滤镜图分隔符 [ ] , ; 时用单引号包裹, 因此表达式、文本和路径可以原样传入.
+package filtergraph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Option 滤镜参数, Key 为空时按位置传递
+type Option struct {
+	Key   string
+	Value string
+}
+
+// Filter 单个滤镜
+type Filter struct {
+	Name    string
+	Options []Option
+}
+
+// New 创建滤镜, args 为按位置传递的参数
+func New(name string, args ...string) *Filter {
+	f := &Filter{Name: name}
+	for _, arg := range args {
+		f.Options = append(f.Options, Option{Value: arg})
+	}
+	return f
+}
+
+// Set 追加命名参数, value 用 fmt.Sprint 格式化
+func (f *Filter) Set(key string, value any) *Filter {
+	f.Options = append(f.Options, Option{Key: key, Value: fmt.Sprint(value)})
+	return f
+}
+
+// String 返回滤镜描述, e.g. "scale=iw/2:-1"
+func (f *Filter) String() string {
+	if len(f.Options) == 0 {
+		return f.Name
+	}
+
+	opts := make([]string, len(f.Options))
+	for i, opt := range f.Options {
+		if opt.Key == "" {
+			// 按位置传递的参数与命名参数同样按参数级别转义, 其中的 = 还会被误认为命名参数
+			opts[i] = Escape(strings.ReplaceAll(optionEscaper.Replace(opt.Value), "=", `\=`), true)
+		} else {
+			opts[i] = opt.Key + "=" + Escape(opt.Value, false)
+		}
+	}
+	return f.Name + "=" + strings.Join(opts, ":")
+}
+
+// 滤镜参数级别需要转义的字符
+var optionEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`)
+
+// Escape 转义参数值, 使其经过滤镜图和滤镜参数两级解析后保持原样
+//
+// escaped 为 true 时 value 已经过滤镜参数级别的转义
+func Escape(value string, escaped bool) string {
+	if !escaped {
+		value = optionEscaper.Replace(value)
+	}
+
+	// 滤镜图级别: 含有分隔符、引号、反斜杠或首尾空白时整体用单引号包裹, 值中的单引号写作 '\''
+	if strings.ContainsAny(value, `[],;'\`) || strings.TrimSpace(value) != value {
+		return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+	}
+	return value
+}
+
+// Chain 滤镜链: 输入依次经过所有滤镜后输出
+type Chain struct {
+	Inputs  []string
+	Filters []*Filter
+	Outputs []string
+}
+
+// Then 在链尾追加滤镜, nil 会被忽略
+func (c *Chain) Then(filters ...*Filter) *Chain {
+	for _, f := range filters {
+		if f != nil {
+			c.Filters = append(c.Filters, f)
+		}
+	}
+	return c
+}
+
+// To 设置链的输出标签, 不设置时输出不加标签
+func (c *Chain) To(labels ...string) *Chain {
+	c.Outputs = labels
+	return c
+}
+
+// String 返回滤镜链描述, e.g. "[0][wm1]overlay=x=0:y=0[v1]"
+func (c *Chain) String() string {
+	var b strings.Builder
+	for _, label := range c.Inputs {
+		b.WriteString("[" + label + "]")
+	}
+	for i, f := range c.Filters {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(f.String())
+	}
+	for _, label := range c.Outputs {
+		b.WriteString("[" + label + "]")
+	}
+	return b.String()
+}
+
+// Graph 滤镜图
+type Graph struct {
+	Chains []*Chain
+}
+
+// Chain 追加一条以 inputs 为输入的滤镜链
+func (g *Graph) Chain(inputs ...string) *Chain {
+	c := &Chain{Inputs: inputs}
+	g.Chains = append(g.Chains, c)
+	return c
+}
+
+// Extend 追加另一个滤镜图中的所有滤镜链
+func (g *Graph) Extend(other *Graph) {
+	g.Chains = append(g.Chains, other.Chains...)
+}
+
+// Last 返回最后一条滤镜链, 滤镜图为空时返回 nil
+func (g *Graph) Last() *Chain {
+	if len(g.Chains) == 0 {
+		return nil
+	}
+	return g.Chains[len(g.Chains)-1]
+}
+
+// FilterNames 按首次出现的顺序返回滤镜图中用到的滤镜名称, 不重复
+func (g *Graph) FilterNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, c := range g.Chains {
+		for _, f := range c.Filters {
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				names = append(names, f.Name)
+			}
+		}
+	}
+	return names
+}
+
+// String 返回滤镜图描述, 滤镜链之间用分号分隔
+func (g *Graph) String() string {
+	chains := make([]string, len(g.Chains))
+	for i, c := range g.Chains {
+		chains[i] = c.String()
+	}
+	return strings.Join(chains, ";")
+}
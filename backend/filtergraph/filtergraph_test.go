@@ -0,0 +1,85 @@
+package filtergraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"普通值", "iw*50/100", "iw*50/100"},
+		{"逗号", "between(t,0,10)", "'between(t,0,10)'"},
+		{"冒号", "12:30", `'12\:30'`},
+		{"单引号", "it's", `'it\'\''s'`},
+		{"反斜杠", `C:\fonts\a.ttf`, `'C\:\\fonts\\a.ttf'`},
+		{"方括号和分号", "[a];b", "'[a];b'"},
+		{"首尾空格", " x ", "' x '"},
+		{"空值", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Escape(tt.value, false); got != tt.want {
+			t.Errorf("%s: 得到 %s, 期望 %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterString(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   string
+	}{
+		{"无参数", New("alphaextract"), "alphaextract"},
+		{"位置参数", New("crop", "64", "32", "10", "20"), "crop=64:32:10:20"},
+		{"命名参数", New("overlay").Set("x", "main_w-overlay_w").Set("y", 0), "overlay=x=main_w-overlay_w:y=0"},
+		{"表达式", New("overlay").Set("enable", "between(t,0,10)"), "overlay=enable='between(t,0,10)'"},
+		{"位置参数含等号", New("drawtext", "a=b"), `drawtext='a\=b'`},
+		{"位置参数含冒号", New("drawtext", "12:30"), `drawtext='12\:30'`},
+		{"位置参数含路径", New("movie", `C:\a`), `movie='C\:\\a'`},
+		{"位置参数含引号", New("drawtext", "it's"), `drawtext='it\'\''s'`},
+		{
+			"文本",
+			New("drawtext").Set("text", "Screener: Zoë's copy, 2024").Set("fontfile", "fonts/a b.ttf"),
+			`drawtext=text='Screener\: Zoë\'\''s copy, 2024':fontfile=fonts/a b.ttf`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.String(); got != tt.want {
+			t.Errorf("%s: 得到 %s, 期望 %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGraphString(t *testing.T) {
+	var g Graph
+	g.Chain("1").Then(New("format", "rgba"), nil, New("scale", "iw*50/100", "-1")).To("wm1")
+	g.Chain("0", "wm1").Then(New("overlay").Set("x", 0).Set("y", 0)).To("v1")
+	g.Chain("v1").Then(New("split")).To("a", "b")
+
+	var tail Graph
+	tail.Chain("a", "b").Then(New("hstack"))
+	g.Extend(&tail)
+	g.Last().Then(New("scale").Set("w", "min(1280,iw)").Set("h", -2))
+
+	want := "[1]format=rgba,scale=iw*50/100:-1[wm1];[0][wm1]overlay=x=0:y=0[v1];[v1]split[a][b];" +
+		"[a][b]hstack,scale=w='min(1280,iw)':h=-2"
+	if got := g.String(); got != want {
+		t.Errorf("滤镜图错误:\n得到 %s\n期望 %s", got, want)
+	}
+
+	names := []string{"format", "scale", "overlay", "split", "hstack"}
+	if got := g.FilterNames(); !reflect.DeepEqual(got, names) {
+		t.Errorf("滤镜名称: 得到 %v, 期望 %v", got, names)
+	}
+
+	var empty Graph
+	if empty.Last() != nil || empty.String() != "" {
+		t.Error("空滤镜图应没有滤镜链")
+	}
+}
@@ -0,0 +1,177 @@
+// Package forensic 在视频帧的亮度平面中嵌入和提取不可见的取证水印
+//
+// 水印以 8x8 像素块为单位嵌入: 每个块携带载荷中的一位, 通过调整块内两个中频 DCT 系数的相对大小表示 0 或 1.
+// 载荷按块序号循环重复, 提取时对所有块 (以及多帧) 投票, 因此可以容忍有损压缩带来的误差.
+// 嵌入和提取要求块网格对齐, 不支持裁剪或缩放后的画面.
+package forensic
+
+import "math"
+
+const (
+	// BlockSize DCT 块大小 (像素)
+	BlockSize = 8
+	// PayloadBits 载荷位数
+	PayloadBits = 64
+	// DefaultStrength 默认嵌入强度, 即两个系数之间的最小差值
+	DefaultStrength = 12.0
+)
+
+// 用于嵌入的两个中频系数位置 (u, v), 对称选取使两者在压缩中受到相近的量化
+var (
+	coefA = [2]int{2, 3}
+	coefB = [2]int{3, 2}
+)
+
+// basis 为 coefA 与 coefB 的 DCT 基函数之差, 系数差值变化 d 时像素变化 d/2*basis
+var basis = func() [BlockSize][BlockSize]float64 {
+	var b [BlockSize][BlockSize]float64
+	for y := 0; y < BlockSize; y++ {
+		for x := 0; x < BlockSize; x++ {
+			b[y][x] = dctBasis(coefA, x, y) - dctBasis(coefB, x, y)
+		}
+	}
+	return b
+}()
+
+// dctBasis 返回正交归一化二维 DCT-II 基函数在 (x, y) 处的值
+func dctBasis(coef [2]int, x, y int) float64 {
+	alpha := func(k int) float64 {
+		if k == 0 {
+			return math.Sqrt(1.0 / BlockSize)
+		}
+		return math.Sqrt(2.0 / BlockSize)
+	}
+	u, v := coef[0], coef[1]
+	return alpha(u) * alpha(v) *
+		math.Cos(float64(2*x+1)*float64(u)*math.Pi/(2*BlockSize)) *
+		math.Cos(float64(2*y+1)*float64(v)*math.Pi/(2*BlockSize))
+}
+
+// blockDiff 计算一个块中 coefA 与 coefB 系数的差值
+func blockDiff(plane []byte, stride, bx, by int) float64 {
+	var diff float64
+	for y := 0; y < BlockSize; y++ {
+		row := plane[(by+y)*stride+bx:]
+		for x := 0; x < BlockSize; x++ {
+			diff += float64(row[x]) * basis[y][x]
+		}
+	}
+	return diff
+}
+
+// scramble 返回第 i 个块的扰码位, 避免载荷中连续相同的位在画面上形成规则图案
+func scramble(i int) bool {
+	// splitmix64
+	z := uint64(i) + 0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	z ^= z >> 31
+	return z&1 == 1
+}
+
+// blockBit 返回第 i 个块应携带的位 (已扰码)
+func blockBit(payload uint64, i int) bool {
+	bit := payload>>(i%PayloadBits)&1 == 1
+	return bit != scramble(i)
+}
+
+// Embedder 将载荷嵌入视频帧
+type Embedder struct {
+	payload  uint64
+	strength float64
+}
+
+// NewEmbedder 创建嵌入器, strength 不大于 0 时使用默认强度
+func NewEmbedder(payload uint64, strength float64) *Embedder {
+	if strength <= 0 {
+		strength = DefaultStrength
+	}
+	return &Embedder{payload: payload, strength: strength}
+}
+
+// EmbedFrame 将载荷嵌入一帧的亮度平面 (每行 width 字节), 就地修改; 不足一个块的边缘保持不变
+func (e *Embedder) EmbedFrame(plane []byte, width, height int) {
+	i := 0
+	for by := 0; by+BlockSize <= height; by += BlockSize {
+		for bx := 0; bx+BlockSize <= width; bx += BlockSize {
+			e.embedBlock(plane, width, bx, by, blockBit(e.payload, i))
+			i++
+		}
+	}
+}
+
+// embedBlock 调整一个块, 使 coefA-coefB 的差值在期望方向上至少达到嵌入强度
+func (e *Embedder) embedBlock(plane []byte, stride, bx, by int, bit bool) {
+	sign := -1.0
+	if bit {
+		sign = 1.0
+	}
+
+	diff := blockDiff(plane, stride, bx, by)
+	if sign*diff >= e.strength {
+		return
+	}
+
+	// 两个系数各调整一半: coefA += d/2, coefB -= d/2
+	d := sign * (e.strength - sign*diff)
+	for y := 0; y < BlockSize; y++ {
+		row := plane[(by+y)*stride+bx:]
+		for x := 0; x < BlockSize; x++ {
+			v := float64(row[x]) + d/2*basis[y][x]
+			row[x] = uint8(math.Round(math.Max(0, math.Min(255, v))))
+		}
+	}
+}
+
+// Detector 从一帧或多帧中提取载荷, 对所有块的结果投票
+type Detector struct {
+	ones   [PayloadBits]int
+	zeros  [PayloadBits]int
+	frames int
+}
+
+// AddFrame 统计一帧亮度平面中各块携带的位
+func (d *Detector) AddFrame(plane []byte, width, height int) {
+	i := 0
+	for by := 0; by+BlockSize <= height; by += BlockSize {
+		for bx := 0; bx+BlockSize <= width; bx += BlockSize {
+			bit := blockDiff(plane, width, bx, by) > 0
+			if bit != scramble(i) {
+				d.ones[i%PayloadBits]++
+			} else {
+				d.zeros[i%PayloadBits]++
+			}
+			i++
+		}
+	}
+	d.frames++
+}
+
+// Frames 返回已统计的帧数
+func (d *Detector) Frames() int {
+	return d.frames
+}
+
+// Payload 返回投票得到的载荷
+func (d *Detector) Payload() uint64 {
+	var payload uint64
+	for i := 0; i < PayloadBits; i++ {
+		if d.ones[i] > d.zeros[i] {
+			payload |= 1 << i
+		}
+	}
+	return payload
+}
+
+// Confidence 返回与投票结果一致的块所占比例 (0.5-1), 未嵌入水印的画面约为 0.5
+func (d *Detector) Confidence() float64 {
+	var agree, total int
+	for i := 0; i < PayloadBits; i++ {
+		agree += max(d.ones[i], d.zeros[i])
+		total += d.ones[i] + d.zeros[i]
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(agree) / float64(total)
+}
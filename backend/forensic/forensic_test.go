@@ -0,0 +1,82 @@
+package forensic
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// testFrame 生成带有渐变和噪声的亮度平面
+func testFrame(width, height int, seed int64) []byte {
+	rng := rand.New(rand.NewSource(seed))
+	plane := make([]byte, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			plane[y*width+x] = uint8(40 + (x+y)%160 + rng.Intn(32))
+		}
+	}
+	return plane
+}
+
+func TestEmbedAndDetect(t *testing.T) {
+	const width, height = 320, 180
+	payload := uint64(0x0123456789ABCDEF)
+
+	tests := []struct {
+		name  string
+		noise int // 嵌入后叠加的随机噪声幅度, 模拟有损压缩
+	}{
+		{"无损", 0},
+		{"噪声", 6},
+	}
+
+	for _, tt := range tests {
+		rng := rand.New(rand.NewSource(1))
+		var detector Detector
+		embedder := NewEmbedder(payload, 0)
+
+		for f := 0; f < 3; f++ {
+			plane := testFrame(width, height, int64(f))
+			embedder.EmbedFrame(plane, width, height)
+			for i := range plane {
+				if tt.noise > 0 {
+					v := int(plane[i]) + rng.Intn(2*tt.noise+1) - tt.noise
+					plane[i] = uint8(max(0, min(255, v)))
+				}
+			}
+			detector.AddFrame(plane, width, height)
+		}
+
+		if got := detector.Payload(); got != payload {
+			t.Errorf("%s: 得到载荷 %016x, 期望 %016x", tt.name, got, payload)
+		}
+		if c := detector.Confidence(); c < 0.8 {
+			t.Errorf("%s: 置信度过低: %v", tt.name, c)
+		}
+	}
+}
+
+func TestDetectUnmarked(t *testing.T) {
+	const width, height = 320, 180
+
+	var detector Detector
+	detector.AddFrame(testFrame(width, height, 1), width, height)
+
+	if c := detector.Confidence(); c > 0.7 {
+		t.Errorf("未嵌入水印的画面置信度过高: %v", c)
+	}
+}
+
+func TestEmbedFrameDistortion(t *testing.T) {
+	const width, height = 64, 64
+
+	original := testFrame(width, height, 1)
+	plane := append([]byte(nil), original...)
+	NewEmbedder(0xFFFF, 0).EmbedFrame(plane, width, height)
+
+	// 单个像素的变化应不超过嵌入强度
+	for i := range plane {
+		if d := int(plane[i]) - int(original[i]); d > int(DefaultStrength) || d < -int(DefaultStrength) {
+			t.Fatalf("像素 %d 变化过大: %d", i, d)
+		}
+	}
+}
@@ -2,10 +2,11 @@ package main
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"image/png"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -197,6 +198,97 @@ func handleSaveWatermark(c *gin.Context) {
 	})
 }
 
+// 处理文字水印渲染请求
+func handleRenderWatermark(c *gin.Context) {
+	// 解析请求
+	var req TextWatermarkRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: "Invalid request format",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 未指定尺寸时使用源文件的显示分辨率 (旋转后)
+	width, height := req.Width, req.Height
+	if width <= 0 || height <= 0 {
+		if req.SourcePath == "" {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Code:    400,
+				Message: "No watermark size or source path provided",
+				Data:    nil,
+			})
+			return
+		}
+
+		var err error
+		width, height, err = probeDisplayResolution(req.SourcePath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, APIResponse{
+				Code:    500,
+				Message: fmt.Sprintf("Failed to probe source resolution: %v", err),
+				Data:    nil,
+			})
+			return
+		}
+	}
+
+	// 渲染水印
+	img, err := renderTextWatermark(req.Settings, width, height)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: fmt.Sprintf("Failed to render watermark: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+
+	// 保存水印图片
+	watermarkDir := "watermarks"
+	if err := os.MkdirAll(watermarkDir, 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Code:    500,
+			Message: "Failed to create watermark directory",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 生成唯一文件名
+	fileName := fmt.Sprintf("watermark_%d.png", time.Now().UnixNano())
+	filePath := filepath.Join(watermarkDir, fileName)
+
+	// 写入文件
+	file, err := os.Create(filePath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Code:    500,
+			Message: "Failed to save watermark",
+			Data:    nil,
+		})
+		return
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Code:    500,
+			Message: "Failed to encode watermark",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Code:    200,
+		Message: "Watermark rendered successfully",
+		Data:    filePath,
+	})
+}
+
 // 处理媒体处理请求
 func handleProcessMedia(c *gin.Context) {
 	// 解析请求
@@ -210,9 +302,28 @@ func handleProcessMedia(c *gin.Context) {
 		return
 	}
 
+	// 校验请求参数
+	if err := validateProcessRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: fmt.Sprintf("Invalid request: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+
 	// 创建新的FFmpeg任务
 	task, err := NewFFmpegTask(req)
 	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Code:    400,
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Data:    nil,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, APIResponse{
 			Code:    500,
 			Message: fmt.Sprintf("Failed to create task: %v", err),
@@ -226,19 +337,12 @@ func handleProcessMedia(c *gin.Context) {
 	taskManager.tasks[task.ID] = task
 	taskManager.mutex.Unlock()
 
-	// 启动任务
-	if err := task.Start(); err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Code:    500,
-			Message: fmt.Sprintf("Failed to start task: %v", err),
-			Data:    nil,
-		})
-		return
-	}
+	// 加入任务队列, 并发数未满时立即启动
+	taskQueue.Enqueue(task)
 
 	c.JSON(http.StatusOK, APIResponse{
 		Code:    200,
-		Message: "Task started successfully",
+		Message: "Task queued successfully",
 		Data:    task.ID,
 	})
 }
@@ -291,11 +395,55 @@ func handleGenerateFFmpegCommand(c *gin.Context) {
 		return
 	}
 
+	// 校验请求参数
+	if err := validateProcessRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: fmt.Sprintf("Invalid request: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+
+	// 不可见水印需要在 Go 中逐帧处理, 无法用单条命令表示
+	if _, ok := invisibleLayer(req); ok {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: "Invisible watermark cannot be expressed as a single FFmpeg command",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 仅在需要时获取视频时长
+	var duration float64
+	if needsDuration(req) {
+		var err error
+		if duration, err = probeDuration(req.SourcePath); err != nil {
+			c.JSON(http.StatusInternalServerError, APIResponse{
+				Code:    500,
+				Message: fmt.Sprintf("Failed to probe source duration: %v", err),
+				Data:    nil,
+			})
+			return
+		}
+	}
+
+	// 预处理水印层: 转换显示时间; 只生成命令字符串, 平铺图层在任务运行时才生成
+	if _, err := prepareLayers(&req, duration, false); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: fmt.Sprintf("Failed to prepare watermark layers: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+
 	// 构建 FFmpeg 命令参数
 	args := buildFFmpegArgs(req)
 
-	// 构建完整命令字符串
-	cmd := fmt.Sprintf("%s %s", AppConfig.FFmpegPath, strings.Join(args, " "))
+	// 构建可以直接粘贴到 shell 中运行的完整命令字符串
+	cmd := formatCommand(AppConfig.FFmpegPath, args)
 
 	c.JSON(http.StatusOK, APIResponse{
 		Code:    200,
@@ -304,6 +452,181 @@ func handleGenerateFFmpegCommand(c *gin.Context) {
 	})
 }
 
+// 处理批量处理请求
+func handleBatchProcess(c *gin.Context) {
+	// 解析请求
+	var req BatchRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: "Invalid request format",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 校验请求参数
+	if err := validateBatchRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: fmt.Sprintf("Invalid request: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+
+	// 创建批量任务
+	batch, err := NewBatchTask(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Code:    500,
+			Message: fmt.Sprintf("Failed to create batch: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+
+	// 保存任务
+	batchManager.mutex.Lock()
+	batchManager.tasks[batch.ID] = batch
+	batchManager.mutex.Unlock()
+
+	// 启动任务
+	batch.Start()
+
+	c.JSON(http.StatusOK, APIResponse{
+		Code:    200,
+		Message: "Batch started successfully",
+		Data:    batch.ID,
+	})
+}
+
+// 处理批量处理状态查询请求
+func handleGetBatchStatus(c *gin.Context) {
+	// 获取批量任务ID
+	batchID := c.Param("batchId")
+	if batchID == "" {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: "No batch ID provided",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 查找任务
+	batchManager.mutex.RLock()
+	batch, exists := batchManager.tasks[batchID]
+	batchManager.mutex.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Code:    404,
+			Message: "Batch not found",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 返回批量任务状态和清单
+	c.JSON(http.StatusOK, APIResponse{
+		Code:    200,
+		Message: "Success",
+		Data:    batch.GetStatus(),
+	})
+}
+
+// 处理水印校验请求
+func handleVerifyMedia(c *gin.Context) {
+	// 解析请求
+	var req VerifyRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: "Invalid request format",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 校验请求参数
+	if err := validateVerifyRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: fmt.Sprintf("Invalid request: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+
+	// 创建校验任务
+	task, err := NewVerifyTask(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Code:    500,
+			Message: fmt.Sprintf("Failed to create task: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+
+	// 保存任务
+	verifyManager.mutex.Lock()
+	verifyManager.tasks[task.ID] = task
+	verifyManager.mutex.Unlock()
+
+	// 启动任务
+	if err := task.Start(); err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Code:    500,
+			Message: fmt.Sprintf("Failed to start task: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Code:    200,
+		Message: "Task started successfully",
+		Data:    task.ID,
+	})
+}
+
+// 处理水印校验状态查询请求
+func handleGetVerifyStatus(c *gin.Context) {
+	// 获取任务ID
+	taskID := c.Param("taskId")
+	if taskID == "" {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: "No task ID provided",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 查找任务
+	verifyManager.mutex.RLock()
+	task, exists := verifyManager.tasks[taskID]
+	verifyManager.mutex.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Code:    404,
+			Message: "Task not found",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 返回任务状态和匹配结果
+	c.JSON(http.StatusOK, APIResponse{
+		Code:    200,
+		Message: "Success",
+		Data:    task.GetStatus(),
+	})
+}
+
 // 处理媒体预览请求
 func handlePreviewMedia(c *gin.Context) {
 	// 获取文件路径
@@ -331,7 +654,7 @@ func handlePreviewMedia(c *gin.Context) {
 	ext := strings.ToLower(filepath.Ext(path))
 
 	// 图片文件直接返回
-	if ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".gif" || ext == ".bmp" {
+	if isImageFile(path) {
 		c.File(path)
 		return
 	}
@@ -355,7 +678,7 @@ func handlePreviewMedia(c *gin.Context) {
 		previewPath := filepath.Join(AppConfig.TempPath, fmt.Sprintf("%d.jpg", time.Now().UnixNano()))
 
 		// 构建 FFmpeg 命令
-		cmd := exec.Command(AppConfig.FFmpegPath,
+		cmd := runner.Command(AppConfig.FFmpegPath,
 			"-ss", timepoint,
 			"-i", path,
 			"-vframes", "1",
@@ -385,3 +708,66 @@ func handlePreviewMedia(c *gin.Context) {
 		Data:    nil,
 	})
 }
+
+// 处理媒体探测请求
+func handleProbeMedia(c *gin.Context) {
+	// 获取文件路径
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Code:    400,
+			Message: "No file path provided",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 检查文件是否存在
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Code:    404,
+			Message: "File not found",
+			Data:    nil,
+		})
+		return
+	}
+
+	info, err := probeMediaInfo(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Code:    500,
+			Message: fmt.Sprintf("Failed to probe media: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Code:    200,
+		Message: "Success",
+		Data:    info,
+	})
+}
+
+// 处理 FFmpeg 能力查询请求
+func handleGetFFmpegCapabilities(c *gin.Context) {
+	// 启动时探测失败 (e.g., FFmpeg 尚未安装) 时重新探测
+	caps := getCapabilities()
+	if caps == nil {
+		if err := DiscoverCapabilities(); err != nil {
+			c.JSON(http.StatusInternalServerError, APIResponse{
+				Code:    500,
+				Message: fmt.Sprintf("Failed to discover FFmpeg capabilities: %v", err),
+				Data:    nil,
+			})
+			return
+		}
+		caps = getCapabilities()
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Code:    200,
+		Message: "Success",
+		Data:    caps,
+	})
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveAPI 用 handler 处理一个请求, 返回状态码和解析后的响应
+func serveAPI(t *testing.T, method, route, target, body string, handler gin.HandlerFunc) (int, APIResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(method, route, handler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	var resp APIResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: 无法解析响应 %q: %v", method, target, w.Body.String(), err)
+	}
+	return w.Code, resp
+}
+
+// writeTestFile 创建一个内容任意的测试文件
+func writeTestFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("fake media"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandleProcessMedia(t *testing.T) {
+	useFakeRunner(t,
+		fakeScript{match: "format=duration", stdout: "4.000000\n"},
+		fakeScript{match: "-progress pipe:1", stdout: fakeProgress(2, 4)},
+	)
+
+	body := `{"sourcePath": "in.mp4", "outputPath": "out.mp4", "watermarkPath": "logo.png", "scale": 50, "opacity": 80}`
+	code, resp := serveAPI(t, "POST", "/api/process", "/api/process", body, handleProcessMedia)
+	if code != http.StatusOK {
+		t.Fatalf("处理请求: 状态码 %d, 消息 %s", code, resp.Message)
+	}
+
+	taskID, _ := resp.Data.(string)
+	taskManager.mutex.RLock()
+	task := taskManager.tasks[taskID]
+	taskManager.mutex.RUnlock()
+	if task == nil {
+		t.Fatalf("任务 %q 未注册", taskID)
+	}
+	waitTask(t, task)
+
+	code, resp = serveAPI(t, "GET", "/api/process/:taskId", "/api/process/"+taskID, "", handleGetProcessStatus)
+	if code != http.StatusOK {
+		t.Fatalf("状态查询: 状态码 %d, 消息 %s", code, resp.Message)
+	}
+	status, _ := resp.Data.(map[string]interface{})
+	if status["status"] != "completed" || status["progress"] != float64(100) {
+		t.Errorf("任务状态: 得到 %v", status)
+	}
+
+	code, _ = serveAPI(t, "GET", "/api/process/:taskId", "/api/process/task_missing", "", handleGetProcessStatus)
+	if code != http.StatusNotFound {
+		t.Errorf("不存在的任务: 状态码 %d, 期望 %d", code, http.StatusNotFound)
+	}
+}
+
+func TestHandleProcessMediaInvalid(t *testing.T) {
+	r := useFakeRunner(t)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"无效 JSON", `{"sourcePath": `, http.StatusBadRequest},
+		{"缺少输出路径", `{"sourcePath": "in.mp4", "watermarkPath": "logo.png", "scale": 50}`, http.StatusBadRequest},
+		{"无效的不透明度", `{"sourcePath": "in.mp4", "outputPath": "out.mp4", "watermarkPath": "logo.png", "scale": 50, "opacity": 120}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		if code, resp := serveAPI(t, "POST", "/api/process", "/api/process", tt.body, handleProcessMedia); code != tt.want {
+			t.Errorf("%s: 状态码 %d (%s), 期望 %d", tt.name, code, resp.Message, tt.want)
+		}
+	}
+
+	// 校验失败时不运行 FFmpeg
+	if commands := r.Commands(); len(commands) != 0 {
+		t.Errorf("执行了命令: %q", commands)
+	}
+}
+
+func TestHandleProcessMediaTimeBeforeStart(t *testing.T) {
+	useFakeRunner(t, fakeScript{match: "format=duration", stdout: "95.000000\n"})
+
+	// 结尾前 200 秒早于 95 秒视频的开头
+	body := `{"sourcePath": "in.mp4", "outputPath": "out.mp4", "watermarkPath": "logo.png", "scale": 50, "opacity": 80, "visibility": {"intervals": [{"start": 0, "end": -200}]}}`
+	if code, resp := serveAPI(t, "POST", "/api/process", "/api/process", body, handleProcessMedia); code != http.StatusBadRequest {
+		t.Errorf("超出视频开头: 状态码 %d (%s), 期望 %d", code, resp.Message, http.StatusBadRequest)
+	}
+}
+
+func TestTiledWatermarkTempFiles(t *testing.T) {
+	useFakeRunner(t,
+		fakeScript{match: "-show_streams", stdout: probeRotatedSmallJSON},
+		fakeScript{match: "format=duration", stdout: "4.000000\n"},
+		fakeScript{match: "-progress pipe:1", stdout: fakeProgress(2, 4)},
+	)
+	AppConfig.TempPath = t.TempDir()
+	t.Cleanup(func() { AppConfig.TempPath = "" })
+
+	source := filepath.Join(t.TempDir(), "in.mp4")
+	writeTestFile(t, source)
+
+	logo := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(logo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(file, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	file.Close()
+
+	// tempFiles 返回临时目录中的文件
+	tempFiles := func() []string {
+		entries, _ := os.ReadDir(AppConfig.TempPath)
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	body := `{"sourcePath": "` + source + `", "outputPath": "out.mp4", "watermarkPath": "` + logo + `", "mode": "tile", "scale": 100, "opacity": 80}`
+
+	// 只生成命令时不写入平铺图层
+	code, resp := serveAPI(t, "POST", "/api/command", "/api/command", body, handleGenerateFFmpegCommand)
+	if code != http.StatusOK {
+		t.Fatalf("生成命令: 状态码 %d, 消息 %s", code, resp.Message)
+	}
+	if files := tempFiles(); len(files) != 0 {
+		t.Errorf("生成命令后留下临时文件: %q", files)
+	}
+
+	// 任务运行时生成平铺图层, 结束后删除
+	task, err := NewFFmpegTask(ProcessRequest{
+		SourcePath:     source,
+		OutputPath:     "out.mp4",
+		WatermarkLayer: WatermarkLayer{WatermarkPath: logo, Mode: "tile", Scale: 100, Opacity: 80},
+	})
+	if err != nil {
+		t.Fatalf("创建FFmpeg任务失败: %v", err)
+	}
+	if files := tempFiles(); len(files) != 1 {
+		t.Fatalf("平铺图层: 临时文件 %q, 期望 1 个", files)
+	}
+	if err := task.Start(); err != nil {
+		t.Fatalf("启动任务失败: %v", err)
+	}
+	waitTask(t, task)
+	if files := tempFiles(); len(files) != 0 {
+		t.Errorf("任务结束后留下临时文件: %q", files)
+	}
+}
+
+func TestHandlePreviewMediaFailure(t *testing.T) {
+	useFakeRunner(t, fakeScript{match: "-vframes 1", stderr: "moov atom not found\n", exitCode: 1})
+	AppConfig.TempPath = t.TempDir()
+	t.Cleanup(func() { AppConfig.TempPath = "" })
+
+	source := filepath.Join(t.TempDir(), "broken.mp4")
+	writeTestFile(t, source)
+
+	code, resp := serveAPI(t, "GET", "/api/preview", "/api/preview?path="+source, "", handlePreviewMedia)
+	if code != http.StatusInternalServerError || resp.Message != "Failed to generate preview" {
+		t.Errorf("预览失败: 状态码 %d, 消息 %s", code, resp.Message)
+	}
+}
+
+func TestHandleProbeMedia(t *testing.T) {
+	r := useFakeRunner(t, fakeScript{match: "-show_streams", stdout: probePortraitJSON})
+
+	source := filepath.Join(t.TempDir(), "portrait.mp4")
+	writeTestFile(t, source)
+
+	for i := 0; i < 2; i++ {
+		code, resp := serveAPI(t, "GET", "/api/probe", "/api/probe?path="+source, "", handleProbeMedia)
+		if code != http.StatusOK {
+			t.Fatalf("探测: 状态码 %d, 消息 %s", code, resp.Message)
+		}
+		info, _ := resp.Data.(map[string]interface{})
+		video, _ := info["video"].(map[string]interface{})
+		if info["path"] != source || video["rotation"] != float64(90) {
+			t.Errorf("探测结果: 得到 %v", info)
+		}
+	}
+
+	// 文件未修改时使用缓存
+	if commands := r.Commands(); len(commands) != 1 {
+		t.Errorf("ffprobe 执行了 %d 次, 期望 1 次", len(commands))
+	}
+
+	if code, _ := serveAPI(t, "GET", "/api/probe", "/api/probe", "", handleProbeMedia); code != http.StatusBadRequest {
+		t.Errorf("缺少路径: 状态码 %d, 期望 %d", code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"ffwatermark/forensic"
+)
+
+// 不可见水印模式: 将载荷嵌入画面的频域, 不叠加可见图像
+const modeInvisible = "invisible"
+
+// parsePayload 解析十六进制载荷 (最多 16 位, 可带 "0x" 前缀)
+func parsePayload(s string) (uint64, error) {
+	hex := strings.TrimPrefix(strings.ToLower(s), "0x")
+	if hex == "" || len(hex) > 16 {
+		return 0, fmt.Errorf("invalid payload: %q", s)
+	}
+	payload, err := strconv.ParseUint(hex, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid payload: %q", s)
+	}
+	return payload, nil
+}
+
+// validateInvisible 校验不可见水印层, 这类水印层不需要水印图片
+func validateInvisible(layer WatermarkLayer) error {
+	if _, err := parsePayload(layer.Payload); err != nil {
+		return err
+	}
+	if layer.Strength < 0 || layer.Strength > 50 {
+		return fmt.Errorf("invalid strength: %v", layer.Strength)
+	}
+	return nil
+}
+
+// validateInvisibleRequest 校验包含不可见水印的请求: 最多一个不可见水印层, 只支持视频, 不能缩放输出
+func validateInvisibleRequest(req ProcessRequest) error {
+	count := 0
+	for _, layer := range req.watermarkLayers() {
+		if layer.Mode == modeInvisible {
+			count++
+		}
+	}
+
+	switch {
+	case count == 0:
+		return nil
+	case count > 1:
+		return fmt.Errorf("only one invisible watermark layer is allowed")
+	case isStillImage(req.SourcePath):
+		return fmt.Errorf("invisible watermark requires a video source")
+	case req.Encoding.MaxWidth > 0 || req.Encoding.MaxHeight > 0:
+		// 提取时需要与嵌入时相同的块网格, 嵌入后不能再缩放
+		return fmt.Errorf("max resolution is not supported with invisible watermark")
+	}
+
+	return nil
+}
+
+// invisibleLayer 返回请求中的不可见水印层
+func invisibleLayer(req ProcessRequest) (WatermarkLayer, bool) {
+	for _, layer := range req.watermarkLayers() {
+		if layer.Mode == modeInvisible {
+			return layer, true
+		}
+	}
+	return WatermarkLayer{}, false
+}
+
+// visibleLayers 返回请求中需要叠加的可见水印层
+func visibleLayers(req ProcessRequest) []WatermarkLayer {
+	var layers []WatermarkLayer
+	for _, layer := range req.watermarkLayers() {
+		if layer.Mode != modeInvisible {
+			layers = append(layers, layer)
+		}
+	}
+	return layers
+}
+
+// buildDecoderArgs 构建解码命令参数: 叠加可见水印层后以 yuv420p 原始帧输出到 stdout
+func buildDecoderArgs(req ProcessRequest) []string {
+	// 关闭自动旋转, 保证帧尺寸与 ffprobe 报告的编码尺寸一致
+	args := []string{"-v", "error", "-noautorotate", "-i", req.SourcePath}
+
+	layers := visibleLayers(req)
+	if len(layers) > 0 {
+		for _, layer := range layers {
+			args = append(args, watermarkInputArgs(layer)...)
+			args = append(args, "-i", layer.WatermarkPath)
+		}
+
+		visible := req
+		visible.Layers = layers
+		args = append(args, "-filter_complex", buildOverlayFilter(visible))
+	} else if len(req.Regions) > 0 {
+		args = append(args, "-filter_complex", buildRegionChains(req.Regions, "0", "").String())
+	}
+
+	return append(args, "-an", "-f", "rawvideo", "-pix_fmt", "yuv420p", "pipe:1")
+}
+
+// buildEncoderArgs 构建编码命令参数: 从 stdin 读取原始帧, 音频取自源文件
+func buildEncoderArgs(req ProcessRequest, width, height int, frameRate string) []string {
+	args := []string{
+		"-progress", "pipe:1",
+		"-nostats",
+		"-f", "rawvideo",
+		"-pix_fmt", "yuv420p",
+		"-video_size", fmt.Sprintf("%dx%d", width, height),
+		"-framerate", frameRate,
+		"-i", "pipe:0",
+		"-i", req.SourcePath,
+		"-map", "0:v",
+		"-map", "1:a?",
+	}
+	args = append(args, buildEncodingArgs(req.Encoding)...)
+
+	return append(args, "-y", req.OutputPath)
+}
+
+// framePipeline 不可见水印处理流程: FFmpeg 解码 → Go 嵌入水印 → FFmpeg 编码
+type framePipeline struct {
+	decoder  Process
+	frames   io.ReadCloser
+	encoder  io.WriteCloser
+	stderr   bytes.Buffer
+	width    int
+	height   int
+	embedder *forensic.Embedder
+	done     chan error
+}
+
+// newFramePipeline 创建解码进程和编码命令, 返回的编码命令由 FFmpegTask 启动和监控
+func newFramePipeline(req ProcessRequest, layer WatermarkLayer) (*framePipeline, Process, error) {
+	payload, err := parsePayload(layer.Payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	width, height, err := probeResolution(req.SourcePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	frameRate, err := probeFrameRate(req.SourcePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &framePipeline{
+		width:    width,
+		height:   height,
+		embedder: forensic.NewEmbedder(payload, layer.Strength),
+		done:     make(chan error, 1),
+	}
+
+	p.decoder = runner.Command(AppConfig.FFmpegPath, buildDecoderArgs(req)...)
+	p.decoder.SetStderr(&p.stderr)
+	if p.frames, err = p.decoder.StdoutPipe(); err != nil {
+		return nil, nil, fmt.Errorf("failed to create decoder pipe: %v", err)
+	}
+
+	encoder := runner.Command(AppConfig.FFmpegPath, buildEncoderArgs(req, width, height, frameRate)...)
+	if p.encoder, err = encoder.StdinPipe(); err != nil {
+		return nil, nil, fmt.Errorf("failed to create encoder pipe: %v", err)
+	}
+
+	return p, encoder, nil
+}
+
+// start 启动解码进程和逐帧嵌入水印的协程, 需要在编码进程启动之后调用
+func (p *framePipeline) start() error {
+	if err := p.decoder.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		err := p.copyFrames()
+		p.encoder.Close()
+		if err != nil {
+			// 编码进程已退出, 结束解码进程以免其阻塞在写入上
+			p.decoder.Kill()
+		}
+		p.done <- err
+	}()
+
+	return nil
+}
+
+// copyFrames 逐帧读取解码输出, 在亮度平面嵌入水印后写入编码进程
+func (p *framePipeline) copyFrames() error {
+	// yuv420p: 完整的 Y 平面, 以及宽高各减半的 U、V 平面
+	lumaSize := p.width * p.height
+	chromaSize := ((p.width + 1) / 2) * ((p.height + 1) / 2)
+	frame := make([]byte, lumaSize+2*chromaSize)
+
+	for {
+		if _, err := io.ReadFull(p.frames, frame); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read frame: %v", err)
+		}
+
+		p.embedder.EmbedFrame(frame[:lumaSize], p.width, p.height)
+
+		if _, err := p.encoder.Write(frame); err != nil {
+			return fmt.Errorf("failed to write frame: %v", err)
+		}
+	}
+}
+
+// wait 等待解码进程和嵌入协程结束, 结合编码进程的错误返回整个流程的结果
+//
+// 解码失败时编码进程通常也会因为没有输入帧而失败, 此时优先返回解码错误
+func (p *framePipeline) wait(encoderErr error) error {
+	copyErr := <-p.done
+	if err := p.decoder.Wait(); err != nil && copyErr == nil {
+		return fmt.Errorf("decoder failed: %v: %s", err, strings.TrimSpace(p.stderr.String()))
+	}
+	if encoderErr != nil {
+		return encoderErr
+	}
+	return copyErr
+}
+
+// kill 结束解码进程
+func (p *framePipeline) kill() {
+	p.decoder.Kill()
+}
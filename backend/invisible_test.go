@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePayload(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    uint64
+		wantErr bool
+	}{
+		{"1f", 0x1f, false},
+		{"0xDEADBEEF", 0xdeadbeef, false},
+		{"ffffffffffffffff", 0xffffffffffffffff, false},
+		{"", 0, true},
+		{"0x", 0, true},
+		{"12345678901234567", 0, true},
+		{"xyz", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePayload(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: 错误 %v, 期望返回错误 %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: 得到 %x, 期望 %x", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestValidateInvisibleRequest(t *testing.T) {
+	invisible := WatermarkLayer{Mode: "invisible", Payload: "42"}
+	visible := WatermarkLayer{WatermarkPath: "logo.png", Scale: 100, Opacity: 100}
+
+	tests := []struct {
+		name    string
+		req     ProcessRequest
+		wantErr bool
+	}{
+		{"单个不可见水印", ProcessRequest{SourcePath: "in.mp4", OutputPath: "out.mp4", WatermarkLayer: invisible}, false},
+		{"与可见水印组合", ProcessRequest{SourcePath: "in.mp4", OutputPath: "out.mp4", Layers: []WatermarkLayer{visible, invisible}}, false},
+		{"缺少载荷", ProcessRequest{SourcePath: "in.mp4", OutputPath: "out.mp4", WatermarkLayer: WatermarkLayer{Mode: "invisible"}}, true},
+		{"多个不可见水印", ProcessRequest{SourcePath: "in.mp4", OutputPath: "out.mp4", Layers: []WatermarkLayer{invisible, invisible}}, true},
+		{"静态图片", ProcessRequest{SourcePath: "in.jpg", OutputPath: "out.jpg", WatermarkLayer: invisible}, true},
+		{
+			"限制分辨率",
+			ProcessRequest{SourcePath: "in.mp4", OutputPath: "out.mp4", WatermarkLayer: invisible, Encoding: EncodingOptions{MaxWidth: 1280}},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		if err := validateProcessRequest(tt.req); (err != nil) != tt.wantErr {
+			t.Errorf("%s: 错误 %v, 期望返回错误 %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildDecoderArgs(t *testing.T) {
+	req := ProcessRequest{
+		SourcePath: "in.mp4",
+		Layers: []WatermarkLayer{
+			{Mode: "invisible", Payload: "42"},
+			{WatermarkPath: "logo.png", Position: "top-left", Scale: 50, Opacity: 100},
+		},
+	}
+
+	want := "-v error -noautorotate -i in.mp4 -i logo.png " +
+		"-filter_complex [1]scale=iw*50/100:-1[wm1];[0][wm1]overlay=x=0:y=0 " +
+		"-an -f rawvideo -pix_fmt yuv420p pipe:1"
+	if got := strings.Join(buildDecoderArgs(req), " "); got != want {
+		t.Errorf("解码参数错误:\n得到 %q\n期望 %q", got, want)
+	}
+
+	// 只有不可见水印时不需要滤镜
+	req.Layers = req.Layers[:1]
+	want = "-v error -noautorotate -i in.mp4 -an -f rawvideo -pix_fmt yuv420p pipe:1"
+	if got := strings.Join(buildDecoderArgs(req), " "); got != want {
+		t.Errorf("解码参数错误:\n得到 %q\n期望 %q", got, want)
+	}
+
+	// 只有不可见水印时仍然需要去除区域
+	req.Regions = []RemovalRegion{{X: 8, Y: 8, Width: 64, Height: 32}}
+	want = "-v error -noautorotate -i in.mp4 -filter_complex [0]delogo=x=8:y=8:w=64:h=32 -an -f rawvideo -pix_fmt yuv420p pipe:1"
+	if got := strings.Join(buildDecoderArgs(req), " "); got != want {
+		t.Errorf("解码参数错误:\n得到 %q\n期望 %q", got, want)
+	}
+}
+
+func TestBuildEncoderArgs(t *testing.T) {
+	req := ProcessRequest{SourcePath: "in.mp4", OutputPath: "out.mp4", Encoding: EncodingOptions{VideoCodec: "libx264", CRF: 18}}
+
+	want := "-progress pipe:1 -nostats -f rawvideo -pix_fmt yuv420p -video_size 1920x1080 -framerate 30000/1001 -i pipe:0 " +
+		"-i in.mp4 -map 0:v -map 1:a? -codec:v libx264 -crf 18 -codec:a copy -y out.mp4"
+	if got := strings.Join(buildEncoderArgs(req, 1920, 1080, "30000/1001"), " "); got != want {
+		t.Errorf("编码参数错误:\n得到 %q\n期望 %q", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+)
+
+// watermarkLayers 返回需要叠加的水印层, 未设置 Layers 时使用顶层的单个水印
+func (r ProcessRequest) watermarkLayers() []WatermarkLayer {
+	if len(r.Layers) > 0 {
+		return r.Layers
+	}
+	return []WatermarkLayer{r.WatermarkLayer}
+}
+
+// clone 返回水印层的深拷贝, 切片字段不与原水印层共享
+func (l WatermarkLayer) clone() WatermarkLayer {
+	l.Visibility.Intervals = slices.Clone(l.Visibility.Intervals)
+	l.Perspective = slices.Clone(l.Perspective)
+	return l
+}
+
+// needsDuration 判断预处理水印层时是否需要视频时长
+func needsDuration(req ProcessRequest) bool {
+	for _, layer := range req.watermarkLayers() {
+		if hasRelativeTimes(layer.Visibility) || needsFadeOutEnd(layer) {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareLayers 预处理所有水印层: 将相对于结尾的显示时间转换为绝对时间, 确定淡出时间, 为平铺模式生成全画面图层
+//
+// 处理后 req.Layers 总是包含全部水印层. render 为 false 时只确定平铺图层的路径, 不生成文件 (用于生成命令字符串);
+// 返回生成的临时文件, 由调用方在任务结束后删除
+func prepareLayers(req *ProcessRequest, duration float64, render bool) ([]string, error) {
+	// 深拷贝所有水印层, 避免修改调用方的切片 (e.g., 批量处理中重复使用的模板)
+	layers := req.watermarkLayers()
+	req.Layers = make([]WatermarkLayer, len(layers))
+	for i, layer := range layers {
+		req.Layers[i] = layer.clone()
+	}
+
+	var tempFiles []string
+	for i := range req.Layers {
+		layer := &req.Layers[i]
+		if err := resolveVisibility(&layer.Visibility, duration); err != nil {
+			removeTempFiles(tempFiles)
+			return nil, fmt.Errorf("layer %d: %w", i+1, err)
+		}
+		if err := resolveEffects(layer, duration); err != nil {
+			removeTempFiles(tempFiles)
+			return nil, fmt.Errorf("layer %d: %v", i+1, err)
+		}
+		if layer.Mode != "tile" {
+			continue
+		}
+		if !render {
+			layer.WatermarkPath = newTilePath()
+			continue
+		}
+		if err := prepareTiledWatermark(req.SourcePath, layer); err != nil {
+			removeTempFiles(tempFiles)
+			return nil, fmt.Errorf("layer %d: failed to prepare tiled watermark: %v", i+1, err)
+		}
+		tempFiles = append(tempFiles, layer.WatermarkPath)
+	}
+
+	return tempFiles, nil
+}
+
+// removeTempFiles 删除临时文件, 文件已不存在时忽略
+func removeTempFiles(files []string) {
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			slog.Warn("删除临时文件失败", "path", file, "error", err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestPrepareLayersDoesNotModifyRequest(t *testing.T) {
+	// 批量处理时同一个模板会被多次预处理
+	template := ProcessRequest{
+		SourcePath: "in.mp4",
+		OutputPath: "out.mp4",
+		Layers: []WatermarkLayer{{
+			WatermarkPath: "logo.png",
+			Scale:         100,
+			Visibility:    VisibilityOptions{Intervals: []TimeRange{{Start: -10}}},
+			Perspective:   []float64{0, 0, 100, 10, 0, 100, 100, 90},
+		}},
+	}
+
+	for _, duration := range []float64{95, 60} {
+		req := template
+		if _, err := prepareLayers(&req, duration, false); err != nil {
+			t.Fatalf("预处理失败: %v", err)
+		}
+		if got, want := req.Layers[0].Visibility.Intervals[0].Start, duration-10; got != want {
+			t.Errorf("时长 %v: 开始时间 %v, 期望 %v", duration, got, want)
+		}
+		req.Layers[0].Perspective[0] = 50
+	}
+
+	layer := template.Layers[0]
+	if layer.Visibility.Intervals[0].Start != -10 || layer.Perspective[0] != 0 {
+		t.Errorf("模板被修改: %+v", layer)
+	}
+}
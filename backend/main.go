@@ -18,6 +18,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 限制同时运行的 FFmpeg 任务数
+	taskQueue.SetWorkers(AppConfig.MaxConcurrentTasks)
+
+	// 探测 FFmpeg 支持的编码器和滤镜, 失败时不检查请求
+	if err := DiscoverCapabilities(); err != nil {
+		slog.Warn("Failed to discover FFmpeg capabilities", "error", err)
+	}
+
 	// 创建 Gin 引擎实例
 	r := gin.Default()
 
@@ -42,12 +50,25 @@ func main() {
 		api.POST("/files", uploadFile)          // 上传文件
 		api.GET("/files/*path", getFile)        // 获取文件
 		api.GET("/preview", handlePreviewMedia) // 获取媒体预览
+		api.GET("/probe", probeMedia)           // 获取媒体信息
+
+		// FFmpeg 相关路由
+		api.GET("/ffmpeg", getFFmpegCapabilities) // 获取 FFmpeg 版本和能力
 
 		// 水印相关路由
 		api.POST("/watermark", saveWatermark)                // 保存水印图片
+		api.POST("/watermark/text", renderWatermark)         // 渲染文字水印
 		api.POST("/process", processMedia)                   // 处理媒体文件
 		api.GET("/process/:taskId", getProcessStatus)        // 获取处理状态
 		api.POST("/generate-command", generateFFmpegCommand) // 生成 FFmpeg 命令
+
+		// 批量处理相关路由
+		api.POST("/batch", batchProcess)           // 按接收者批量处理
+		api.GET("/batch/:batchId", getBatchStatus) // 获取批量处理状态
+
+		// 水印校验相关路由
+		api.POST("/verify", verifyMedia)            // 校验媒体文件中的水印
+		api.GET("/verify/:taskId", getVerifyStatus) // 获取校验状态
 	}
 
 	// 启动服务器
@@ -72,11 +93,26 @@ func getFile(c *gin.Context) {
 	handleGetFile(c)
 }
 
+// 获取媒体信息
+func probeMedia(c *gin.Context) {
+	handleProbeMedia(c)
+}
+
+// 获取 FFmpeg 版本和能力
+func getFFmpegCapabilities(c *gin.Context) {
+	handleGetFFmpegCapabilities(c)
+}
+
 // 保存水印图片
 func saveWatermark(c *gin.Context) {
 	handleSaveWatermark(c)
 }
 
+// 渲染文字水印
+func renderWatermark(c *gin.Context) {
+	handleRenderWatermark(c)
+}
+
 // 处理媒体文件
 func processMedia(c *gin.Context) {
 	handleProcessMedia(c)
@@ -91,3 +127,23 @@ func getProcessStatus(c *gin.Context) {
 func generateFFmpegCommand(c *gin.Context) {
 	handleGenerateFFmpegCommand(c)
 }
+
+// 校验媒体文件中的水印
+func verifyMedia(c *gin.Context) {
+	handleVerifyMedia(c)
+}
+
+// 获取校验状态
+func getVerifyStatus(c *gin.Context) {
+	handleGetVerifyStatus(c)
+}
+
+// 按接收者批量处理
+func batchProcess(c *gin.Context) {
+	handleBatchProcess(c)
+}
+
+// 获取批量处理状态
+func getBatchStatus(c *gin.Context) {
+	handleGetBatchStatus(c)
+}
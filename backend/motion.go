@@ -0,0 +1,83 @@
+package main
+
+import "fmt"
+
+// 水印运动类型
+const (
+	motionNone   = "none"
+	motionScroll = "scroll" // 沿直线滚动穿过画面
+	motionBounce = "bounce" // 在画面内碰壁反弹
+	motionOrbit  = "orbit"  // 绕画面中心做圆/椭圆运动
+)
+
+// validateMotion 校验水印运动参数
+func validateMotion(layer WatermarkLayer) error {
+	m := layer.Motion
+	switch m.Type {
+	case "", motionNone:
+		return nil
+	case motionScroll:
+		switch m.Direction {
+		case "left", "right", "up", "down":
+		default:
+			return fmt.Errorf("invalid scroll direction: %q", m.Direction)
+		}
+	case motionBounce:
+	case motionOrbit:
+		if m.RadiusX < 0 || m.RadiusY < 0 || m.RadiusX > 50 || m.RadiusY > 50 {
+			return fmt.Errorf("invalid orbit radius: %vx%v", m.RadiusX, m.RadiusY)
+		}
+	default:
+		return fmt.Errorf("invalid motion type: %q", m.Type)
+	}
+
+	if m.Speed <= 0 {
+		return fmt.Errorf("invalid motion speed: %v", m.Speed)
+	}
+	if layer.Mode == "tile" {
+		return fmt.Errorf("motion is not supported in tile mode")
+	}
+
+	return nil
+}
+
+// buildMotionExpr 构建随时间变化的 overlay x/y 表达式, 没有设置运动时返回 false
+func buildMotionExpr(layer WatermarkLayer) (string, string, bool) {
+	m := layer.Motion
+	speed := formatNumber(m.Speed)
+
+	anchor, ok := anchors[layer.Position]
+	if !ok {
+		anchor = anchors["top-left"]
+	}
+	x := axisExpr(anchor[0], "main_w", "overlay_w", layer.Margin, layer.OffsetX, layer.Unit)
+	y := axisExpr(anchor[1], "main_h", "overlay_h", layer.Margin, layer.OffsetY, layer.Unit)
+
+	switch m.Type {
+	case motionScroll:
+		// 速度单位: 像素/秒, 水印完全移出画面后从另一侧重新进入
+		switch m.Direction {
+		case "left":
+			x = fmt.Sprintf("main_w-mod(t*%s,main_w+overlay_w)", speed)
+		case "right":
+			x = fmt.Sprintf("mod(t*%s,main_w+overlay_w)-overlay_w", speed)
+		case "up":
+			y = fmt.Sprintf("main_h-mod(t*%s,main_h+overlay_h)", speed)
+		case "down":
+			y = fmt.Sprintf("mod(t*%s,main_h+overlay_h)-overlay_h", speed)
+		}
+	case motionBounce:
+		// 速度单位: 像素/秒, 两个方向以相同速度运动, 形成三角波轨迹;
+		// 水印不小于画面时运动范围按 1 像素计算, 避免除以 0 或负数
+		x = fmt.Sprintf("abs(mod(t*%s,2*max(1,main_w-overlay_w))-max(1,main_w-overlay_w))", speed)
+		y = fmt.Sprintf("abs(mod(t*%s,2*max(1,main_h-overlay_h))-max(1,main_h-overlay_h))", speed)
+	case motionOrbit:
+		// 速度单位: 度/秒, 半径为画面宽/高的百分比
+		x = fmt.Sprintf("(main_w-overlay_w)/2+main_w*%s/100*cos(t*%s*PI/180)", formatNumber(m.RadiusX), speed)
+		y = fmt.Sprintf("(main_h-overlay_h)/2+main_h*%s/100*sin(t*%s*PI/180)", formatNumber(m.RadiusY), speed)
+	default:
+		return "", "", false
+	}
+
+	return x, y, true
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestBuildMotionExpr(t *testing.T) {
+	tests := []struct {
+		name  string
+		layer WatermarkLayer
+		want  string
+	}{
+		{
+			"scroll left along bottom",
+			WatermarkLayer{Position: "bottom", Margin: 10, Motion: MotionOptions{Type: "scroll", Direction: "left", Speed: 120}},
+			"x='main_w-mod(t*120,main_w+overlay_w)':y=main_h-overlay_h-10",
+		},
+		{
+			"bounce",
+			WatermarkLayer{Motion: MotionOptions{Type: "bounce", Speed: 80}},
+			"x='abs(mod(t*80,2*max(1,main_w-overlay_w))-max(1,main_w-overlay_w))':y='abs(mod(t*80,2*max(1,main_h-overlay_h))-max(1,main_h-overlay_h))'",
+		},
+		{
+			"orbit",
+			WatermarkLayer{Motion: MotionOptions{Type: "orbit", Speed: 30, RadiusX: 40, RadiusY: 25}},
+			"x=(main_w-overlay_w)/2+main_w*40/100*cos(t*30*PI/180):y=(main_h-overlay_h)/2+main_h*25/100*sin(t*30*PI/180)",
+		},
+	}
+
+	for _, tt := range tests {
+		x, y, ok := buildMotionExpr(tt.layer)
+		if got := positionOptions(x, y); !ok || got != tt.want {
+			t.Errorf("%s: 得到 %q, 期望 %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, _, ok := buildMotionExpr(WatermarkLayer{}); ok {
+		t.Error("未设置运动类型时不应生成运动表达式")
+	}
+}
+
+func TestValidateMotion(t *testing.T) {
+	tests := []struct {
+		name    string
+		layer   WatermarkLayer
+		wantErr bool
+	}{
+		{"none", WatermarkLayer{}, false},
+		{"scroll", WatermarkLayer{Motion: MotionOptions{Type: "scroll", Direction: "up", Speed: 50}}, false},
+		{"scroll without direction", WatermarkLayer{Motion: MotionOptions{Type: "scroll", Speed: 50}}, true},
+		{"zero speed", WatermarkLayer{Motion: MotionOptions{Type: "bounce"}}, true},
+		{"orbit radius too large", WatermarkLayer{Motion: MotionOptions{Type: "orbit", Speed: 10, RadiusX: 60}}, true},
+		{"tile mode", WatermarkLayer{Mode: "tile", Motion: MotionOptions{Type: "bounce", Speed: 10}}, true},
+		{"unknown type", WatermarkLayer{Motion: MotionOptions{Type: "spin", Speed: 10}}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateMotion(tt.layer); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr = %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 九宫格锚点, 值为 {列, 行}, 0/1/2 分别表示 左(上)/中/右(下)
+var anchors = map[string][2]int{
+	"top-left":     {0, 0},
+	"top":          {1, 0},
+	"top-right":    {2, 0},
+	"left":         {0, 1},
+	"center":       {1, 1},
+	"right":        {2, 1},
+	"bottom-left":  {0, 2},
+	"bottom":       {1, 2},
+	"bottom-right": {2, 2},
+}
+
+// 偏移量和边距的单位
+const (
+	unitPixel   = "px"
+	unitPercent = "%"
+)
+
+// validatePosition 校验水印位置参数
+func validatePosition(layer WatermarkLayer) error {
+	if layer.Position != "" {
+		if _, ok := anchors[layer.Position]; !ok {
+			return fmt.Errorf("invalid position: %q", layer.Position)
+		}
+	}
+
+	switch layer.Unit {
+	case "", unitPixel, unitPercent:
+	default:
+		return fmt.Errorf("invalid offset unit: %q", layer.Unit)
+	}
+
+	if layer.Margin < 0 {
+		return fmt.Errorf("invalid margin: %v", layer.Margin)
+	}
+
+	return nil
+}
+
+// buildPositionExpr 根据锚点、偏移量和边距构建 overlay 的 x/y 表达式
+func buildPositionExpr(layer WatermarkLayer) (string, string) {
+	anchor, ok := anchors[layer.Position]
+	if !ok {
+		anchor = anchors["top-left"] // 默认左上角
+	}
+
+	x := axisExpr(anchor[0], "main_w", "overlay_w", layer.Margin, layer.OffsetX, layer.Unit)
+	y := axisExpr(anchor[1], "main_h", "overlay_h", layer.Margin, layer.OffsetY, layer.Unit)
+
+	return x, y
+}
+
+// axisExpr 构建单个坐标轴的位置表达式
+//
+// align 为 0/1/2 时分别贴近起始边、居中、贴近结束边, 边距只作用于贴边的情况
+func axisExpr(align int, mainSize, overlaySize string, margin, offset float64, unit string) string {
+	var terms []string
+	switch align {
+	case 0:
+		terms = append(terms, lengthExpr(margin, mainSize, unit))
+	case 1:
+		terms = append(terms, fmt.Sprintf("(%s-%s)/2", mainSize, overlaySize))
+	case 2:
+		terms = append(terms, mainSize+"-"+overlaySize)
+		if margin != 0 {
+			terms = append(terms, "-"+lengthExpr(margin, mainSize, unit))
+		}
+	}
+
+	if offset > 0 {
+		terms = append(terms, "+"+lengthExpr(offset, mainSize, unit))
+	} else if offset < 0 {
+		terms = append(terms, "-"+lengthExpr(-offset, mainSize, unit))
+	}
+
+	// 起始边无边距时省略多余的 "0"
+	if terms[0] == "0" && len(terms) > 1 {
+		return strings.TrimPrefix(strings.Join(terms[1:], ""), "+")
+	}
+	return strings.Join(terms, "")
+}
+
+// lengthExpr 将像素或百分比长度转换为表达式
+func lengthExpr(v float64, mainSize, unit string) string {
+	if unit == unitPercent && v != 0 {
+		return fmt.Sprintf("%s*%s/100", mainSize, formatNumber(v))
+	}
+	return formatNumber(v)
+}
+
+// formatNumber 格式化数值, 去掉多余的小数位
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
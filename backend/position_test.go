@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestBuildPositionExpr(t *testing.T) {
+	tests := []struct {
+		name  string
+		layer WatermarkLayer
+		want  string
+	}{
+		{"default", WatermarkLayer{}, "x=0:y=0"},
+		{"center", WatermarkLayer{Position: "center"}, "x=(main_w-overlay_w)/2:y=(main_h-overlay_h)/2"},
+		{"bottom-right", WatermarkLayer{Position: "bottom-right"}, "x=main_w-overlay_w:y=main_h-overlay_h"},
+		{"top with margin", WatermarkLayer{Position: "top", Margin: 20}, "x=(main_w-overlay_w)/2:y=20"},
+		{
+			"bottom-right with margin",
+			WatermarkLayer{Position: "bottom-right", Margin: 16},
+			"x=main_w-overlay_w-16:y=main_h-overlay_h-16",
+		},
+		{
+			"left with negative offset",
+			WatermarkLayer{Position: "left", OffsetX: -8, OffsetY: 4.5},
+			"x=-8:y=(main_h-overlay_h)/2+4.5",
+		},
+		{
+			"percent margin and offset",
+			WatermarkLayer{Position: "top-right", Margin: 5, OffsetY: 10, Unit: "%"},
+			"x=main_w-overlay_w-main_w*5/100:y=main_h*5/100+main_h*10/100",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := positionOptions(buildPositionExpr(tt.layer)); got != tt.want {
+			t.Errorf("%s: 得到 %q, 期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateProcessRequest(t *testing.T) {
+	valid := ProcessRequest{
+		SourcePath: "in.mp4",
+		OutputPath: "out.mp4",
+		WatermarkLayer: WatermarkLayer{
+			WatermarkPath: "wm.png",
+			Position:      "bottom",
+			Scale:         100,
+			Opacity:       50,
+		},
+	}
+	if err := validateProcessRequest(valid); err != nil {
+		t.Fatalf("合法请求校验失败: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *ProcessRequest)
+	}{
+		{"unknown position", func(r *ProcessRequest) { r.Position = "middle" }},
+		{"unknown unit", func(r *ProcessRequest) { r.Unit = "em" }},
+		{"negative margin", func(r *ProcessRequest) { r.Margin = -1 }},
+		{"unknown mode", func(r *ProcessRequest) { r.Mode = "mosaic" }},
+		{"zero scale", func(r *ProcessRequest) { r.Scale = 0 }},
+		{"opacity out of range", func(r *ProcessRequest) { r.Opacity = 101 }},
+		{"missing source", func(r *ProcessRequest) { r.SourcePath = "" }},
+		{"invalid layer", func(r *ProcessRequest) { r.Layers = []WatermarkLayer{r.WatermarkLayer, {}} }},
+	}
+
+	for _, tt := range tests {
+		req := valid
+		tt.modify(&req)
+		if err := validateProcessRequest(req); err == nil {
+			t.Errorf("%s: 期望返回错误", tt.name)
+		}
+	}
+}
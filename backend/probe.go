@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxProbeCacheEntries 探测结果缓存的最大条目数, 超出时淘汰最久未使用的条目
+const maxProbeCacheEntries = 256
+
+// 探测结果缓存, 以文件路径为键, 文件修改时间或大小变化后重新探测
+var (
+	probeCache = struct {
+		entries map[string]*probeCacheEntry
+		clock   uint64 // 每次访问递增, 用于淘汰最久未使用的条目
+		mutex   sync.Mutex
+	}{
+		entries: make(map[string]*probeCacheEntry),
+	}
+)
+
+// probeCacheEntry 缓存的探测结果及探测时的文件状态
+type probeCacheEntry struct {
+	modTime  time.Time
+	size     int64
+	info     MediaInfo
+	lastUsed uint64
+}
+
+// cachedProbe 返回文件状态未变化时缓存的探测结果; 文件已变化时删除过期条目
+func cachedProbe(key string, stat os.FileInfo) (MediaInfo, bool) {
+	probeCache.mutex.Lock()
+	defer probeCache.mutex.Unlock()
+
+	entry, ok := probeCache.entries[key]
+	if !ok {
+		return MediaInfo{}, false
+	}
+	if !entry.modTime.Equal(stat.ModTime()) || entry.size != stat.Size() {
+		delete(probeCache.entries, key)
+		return MediaInfo{}, false
+	}
+
+	probeCache.clock++
+	entry.lastUsed = probeCache.clock
+	return entry.info, true
+}
+
+// storeProbe 缓存探测结果, 缓存已满时淘汰最久未使用的条目
+func storeProbe(key string, stat os.FileInfo, info MediaInfo) {
+	probeCache.mutex.Lock()
+	defer probeCache.mutex.Unlock()
+
+	if _, ok := probeCache.entries[key]; !ok && len(probeCache.entries) >= maxProbeCacheEntries {
+		var oldest string
+		for k, entry := range probeCache.entries {
+			if oldest == "" || entry.lastUsed < probeCache.entries[oldest].lastUsed {
+				oldest = k
+			}
+		}
+		delete(probeCache.entries, oldest)
+	}
+
+	probeCache.clock++
+	probeCache.entries[key] = &probeCacheEntry{modTime: stat.ModTime(), size: stat.Size(), info: info, lastUsed: probeCache.clock}
+}
+
+// ffprobeOutput ffprobe -print_format json 的输出
+type ffprobeOutput struct {
+	Streams []ffprobeStream `json:"streams"`
+	Format  ffprobeFormat   `json:"format"`
+}
+
+// ffprobeStream ffprobe 输出的流信息, 数值字段中部分以字符串表示
+type ffprobeStream struct {
+	Index         int               `json:"index"`
+	CodecName     string            `json:"codec_name"`
+	CodecType     string            `json:"codec_type"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	PixFmt        string            `json:"pix_fmt"`
+	RFrameRate    string            `json:"r_frame_rate"`
+	AvgFrameRate  string            `json:"avg_frame_rate"`
+	SampleRate    string            `json:"sample_rate"`
+	Channels      int               `json:"channels"`
+	ChannelLayout string            `json:"channel_layout"`
+	BitRate       string            `json:"bit_rate"`
+	Disposition   map[string]int    `json:"disposition"`
+	Tags          map[string]string `json:"tags"`
+	SideDataList  []struct {
+		SideDataType string  `json:"side_data_type"`
+		Rotation     float64 `json:"rotation"`
+	} `json:"side_data_list"`
+}
+
+// ffprobeFormat ffprobe 输出的容器信息
+type ffprobeFormat struct {
+	FormatName string `json:"format_name"`
+	Duration   string `json:"duration"`
+	Size       string `json:"size"`
+	BitRate    string `json:"bit_rate"`
+}
+
+// ffprobePath 返回 LoadConfig 查找到的 ffprobe 路径
+func ffprobePath() string {
+	return AppConfig.FFprobePath
+}
+
+// probeDuration 获取媒体文件时长 (秒)
+func probeDuration(path string) (float64, error) {
+	cmd := runner.Command(ffprobePath(),
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run ffprobe: %v", err)
+	}
+
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse duration: %v", err)
+	}
+
+	return duration, nil
+}
+
+// probeResolution 获取媒体文件第一个视频流的编码分辨率, 不考虑旋转; 用于关闭自动旋转 (-noautorotate) 解码的原始帧
+func probeResolution(path string) (int, int, error) {
+	cmd := runner.Command(ffprobePath(),
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height",
+		"-of", "csv=s=x:p=0",
+		path,
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to run ffprobe: %v", err)
+	}
+
+	var width, height int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(out)), "%dx%d", &width, &height); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse resolution: %v", err)
+	}
+
+	return width, height, nil
+}
+
+// probeDisplayResolution 获取媒体文件第一个视频流的显示分辨率
+//
+// FFmpeg 默认按旋转元数据自动旋转画面, 旋转 90/270 度的视频 (e.g., 手机竖拍) 叠加水印时宽高与编码分辨率相反;
+// 静态图片由 sourceFilter 按 EXIF 方向摆正, 同样需要交换宽高
+func probeDisplayResolution(path string) (int, int, error) {
+	info, err := probeMediaInfo(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	if info.Video == nil {
+		return 0, 0, fmt.Errorf("no video stream found")
+	}
+
+	if isStillImage(path) {
+		width, height := info.Video.Width, info.Video.Height
+		// EXIF 方向 5-8 包含 90/270 度旋转
+		if orientation, err := readExifOrientation(path); err == nil && orientation >= 5 && orientation <= 8 {
+			width, height = height, width
+		}
+		return width, height, nil
+	}
+
+	return info.Video.DisplayWidth, info.Video.DisplayHeight, nil
+}
+
+// probeFrameRate 获取媒体文件第一个视频流的帧率 (e.g., "30000/1001")
+func probeFrameRate(path string) (string, error) {
+	cmd := runner.Command(ffprobePath(),
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=r_frame_rate",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run ffprobe: %v", err)
+	}
+
+	rate := strings.TrimSpace(string(out))
+	var num, den int
+	if _, err := fmt.Sscanf(rate, "%d/%d", &num, &den); err != nil || num <= 0 || den <= 0 {
+		return "", fmt.Errorf("failed to parse frame rate: %q", rate)
+	}
+
+	return rate, nil
+}
+
+// probeMediaInfo 获取媒体文件的格式、视频流和音频轨道信息, 结果按路径和修改时间缓存
+func probeMediaInfo(path string) (MediaInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	if stat.IsDir() {
+		return MediaInfo{}, fmt.Errorf("%s is a directory", path)
+	}
+
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+
+	if info, ok := cachedProbe(key, stat); ok {
+		return info, nil
+	}
+
+	cmd := runner.Command(ffprobePath(),
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return MediaInfo{}, fmt.Errorf("failed to run ffprobe: %v", err)
+	}
+
+	info, err := parseProbeOutput(out)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	info.Path = path
+	if info.Size == 0 {
+		info.Size = stat.Size()
+	}
+
+	storeProbe(key, stat, info)
+
+	return info, nil
+}
+
+// parseProbeOutput 解析 ffprobe 的 JSON 输出
+//
+// 视频取第一个非封面图的视频流, 所有音频流作为音频轨道
+func parseProbeOutput(data []byte) (MediaInfo, error) {
+	var out ffprobeOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return MediaInfo{}, fmt.Errorf("failed to parse ffprobe output: %v", err)
+	}
+
+	info := MediaInfo{
+		Format:   out.Format.FormatName,
+		Duration: parseFloat(out.Format.Duration),
+		BitRate:  parseInt(out.Format.BitRate),
+		Size:     parseInt(out.Format.Size),
+		Audio:    make([]AudioStream, 0),
+	}
+
+	for _, s := range out.Streams {
+		switch s.CodecType {
+		case "video":
+			if info.Video != nil || s.Disposition["attached_pic"] == 1 {
+				continue
+			}
+			info.Video = parseVideoStream(s)
+		case "audio":
+			info.Audio = append(info.Audio, AudioStream{
+				Index:         s.Index,
+				Codec:         s.CodecName,
+				SampleRate:    int(parseInt(s.SampleRate)),
+				Channels:      s.Channels,
+				ChannelLayout: s.ChannelLayout,
+				Language:      s.Tags["language"],
+				BitRate:       parseInt(s.BitRate),
+			})
+		}
+	}
+
+	if info.Video == nil && len(info.Audio) == 0 {
+		return MediaInfo{}, fmt.Errorf("no video or audio stream found")
+	}
+
+	return info, nil
+}
+
+// parseVideoStream 将 ffprobe 视频流转换为 VideoStream
+func parseVideoStream(s ffprobeStream) *VideoStream {
+	v := &VideoStream{
+		Index:         s.Index,
+		Codec:         s.CodecName,
+		Width:         s.Width,
+		Height:        s.Height,
+		DisplayWidth:  s.Width,
+		DisplayHeight: s.Height,
+		FrameRate:     s.RFrameRate,
+		PixelFormat:   s.PixFmt,
+		Rotation:      streamRotation(s),
+		BitRate:       parseInt(s.BitRate),
+	}
+
+	// 可变帧率的视频 r_frame_rate 可能远大于实际帧率, 优先使用平均帧率
+	if fps := parseRate(s.AvgFrameRate); fps > 0 {
+		v.FrameRate, v.FPS = s.AvgFrameRate, fps
+	} else {
+		v.FPS = parseRate(s.RFrameRate)
+	}
+
+	if v.Rotation == 90 || v.Rotation == 270 {
+		v.DisplayWidth, v.DisplayHeight = s.Height, s.Width
+	}
+
+	return v
+}
+
+// streamRotation 获取视频流的顺时针旋转角度, 取 0、90、180、270 之一
+//
+// 新版 ffprobe 在 Display Matrix 中以逆时针角度报告旋转, 旧版使用 rotate 标签 (顺时针)
+func streamRotation(s ffprobeStream) int {
+	degrees := 0.0
+	if rotate, ok := s.Tags["rotate"]; ok {
+		degrees = parseFloat(rotate)
+	}
+	for _, sd := range s.SideDataList {
+		if sd.SideDataType == "Display Matrix" {
+			degrees = -sd.Rotation
+		}
+	}
+
+	quarter := int(math.Round(degrees/90)) % 4
+	if quarter < 0 {
+		quarter += 4
+	}
+	return quarter * 90
+}
+
+// parseRate 解析 "num/den" 形式的帧率, 无效时返回 0
+func parseRate(rate string) float64 {
+	var num, den float64
+	if _, err := fmt.Sscanf(rate, "%g/%g", &num, &den); err != nil || num <= 0 || den <= 0 {
+		return 0
+	}
+	return num / den
+}
+
+// parseFloat 解析 ffprobe 以字符串表示的浮点数, 缺失或为 "N/A" 时返回 0
+func parseFloat(value string) float64 {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// parseInt 解析 ffprobe 以字符串表示的整数, 缺失或为 "N/A" 时返回 0
+func parseInt(value string) int64 {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
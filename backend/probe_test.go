@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// 手机竖拍视频: 新版 ffprobe 以 Display Matrix 报告旋转, 带一个封面图和两条音轨
+const probePortraitJSON = `{
+	"streams": [
+		{
+			"index": 0,
+			"codec_name": "h264",
+			"codec_type": "video",
+			"width": 1920,
+			"height": 1080,
+			"pix_fmt": "yuv420p",
+			"r_frame_rate": "60/1",
+			"avg_frame_rate": "30000/1001",
+			"bit_rate": "8000000",
+			"disposition": {"default": 1, "attached_pic": 0},
+			"side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]
+		},
+		{
+			"index": 1,
+			"codec_name": "aac",
+			"codec_type": "audio",
+			"sample_rate": "48000",
+			"channels": 2,
+			"channel_layout": "stereo",
+			"bit_rate": "128000",
+			"tags": {"language": "eng"}
+		},
+		{
+			"index": 2,
+			"codec_name": "ac3",
+			"codec_type": "audio",
+			"sample_rate": "44100",
+			"channels": 6,
+			"channel_layout": "5.1(side)",
+			"bit_rate": "N/A",
+			"tags": {"language": "jpn"}
+		},
+		{
+			"index": 3,
+			"codec_name": "mjpeg",
+			"codec_type": "video",
+			"width": 600,
+			"height": 600,
+			"disposition": {"default": 0, "attached_pic": 1}
+		}
+	],
+	"format": {
+		"format_name": "mov,mp4,m4a,3gp,3g2,mj2",
+		"duration": "12.345000",
+		"size": "12582912",
+		"bit_rate": "8154321"
+	}
+}`
+
+// 静态图片: 没有时长, 平均帧率为 0/0
+const probeImageJSON = `{
+	"streams": [
+		{
+			"index": 0,
+			"codec_name": "png",
+			"codec_type": "video",
+			"width": 640,
+			"height": 480,
+			"pix_fmt": "rgba",
+			"r_frame_rate": "25/1",
+			"avg_frame_rate": "0/0"
+		}
+	],
+	"format": {
+		"format_name": "png_pipe",
+		"size": "20480"
+	}
+}`
+
+func TestParseProbeOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    MediaInfo
+		wantErr bool
+	}{
+		{
+			"竖拍视频",
+			probePortraitJSON,
+			MediaInfo{
+				Format:   "mov,mp4,m4a,3gp,3g2,mj2",
+				Duration: 12.345,
+				BitRate:  8154321,
+				Size:     12582912,
+				Video: &VideoStream{
+					Index:         0,
+					Codec:         "h264",
+					Width:         1920,
+					Height:        1080,
+					DisplayWidth:  1080,
+					DisplayHeight: 1920,
+					FrameRate:     "30000/1001",
+					FPS:           30000.0 / 1001,
+					PixelFormat:   "yuv420p",
+					Rotation:      90,
+					BitRate:       8000000,
+				},
+				Audio: []AudioStream{
+					{Index: 1, Codec: "aac", SampleRate: 48000, Channels: 2, ChannelLayout: "stereo", Language: "eng", BitRate: 128000},
+					{Index: 2, Codec: "ac3", SampleRate: 44100, Channels: 6, ChannelLayout: "5.1(side)", Language: "jpn"},
+				},
+			},
+			false,
+		},
+		{
+			"静态图片",
+			probeImageJSON,
+			MediaInfo{
+				Format: "png_pipe",
+				Size:   20480,
+				Video: &VideoStream{
+					Codec:         "png",
+					Width:         640,
+					Height:        480,
+					DisplayWidth:  640,
+					DisplayHeight: 480,
+					FrameRate:     "25/1",
+					FPS:           25,
+					PixelFormat:   "rgba",
+				},
+				Audio: []AudioStream{},
+			},
+			false,
+		},
+		{"没有音视频流", `{"streams": [], "format": {"format_name": "ass"}}`, MediaInfo{}, true},
+		{"无效 JSON", `{"streams": [`, MediaInfo{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseProbeOutput([]byte(tt.input))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: 错误 %v, 期望返回错误 %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if !reflect.DeepEqual(got.Video, tt.want.Video) {
+			t.Errorf("%s: 视频流得到 %+v, 期望 %+v", tt.name, got.Video, tt.want.Video)
+		}
+		got.Video, tt.want.Video = nil, nil
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: 得到 %+v, 期望 %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStreamRotation(t *testing.T) {
+	type sideData = struct {
+		SideDataType string  `json:"side_data_type"`
+		Rotation     float64 `json:"rotation"`
+	}
+
+	tests := []struct {
+		name   string
+		stream ffprobeStream
+		want   int
+	}{
+		{"无旋转", ffprobeStream{}, 0},
+		{"rotate 标签", ffprobeStream{Tags: map[string]string{"rotate": "270"}}, 270},
+		{"Display Matrix 逆时针", ffprobeStream{SideDataList: []sideData{{"Display Matrix", 90}}}, 270},
+		{"Display Matrix 顺时针", ffprobeStream{SideDataList: []sideData{{"Display Matrix", -90}}}, 90},
+		{"Display Matrix 倒置", ffprobeStream{SideDataList: []sideData{{"Display Matrix", 180}}}, 180},
+		{"非直角取最近", ffprobeStream{Tags: map[string]string{"rotate": "-95"}}, 270},
+		{"其他附加数据", ffprobeStream{SideDataList: []sideData{{"Stereo 3D", 90}}}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := streamRotation(tt.stream); got != tt.want {
+			t.Errorf("%s: 得到 %d, 期望 %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProbeCache(t *testing.T) {
+	saved := probeCache.entries
+	probeCache.entries = make(map[string]*probeCacheEntry)
+	t.Cleanup(func() { probeCache.entries = saved })
+
+	path := filepath.Join(t.TempDir(), "in.mp4")
+	writeTestFile(t, path)
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 填满缓存后再次使用第一个条目, 新条目淘汰最久未使用的第二个条目
+	for i := 0; i < maxProbeCacheEntries; i++ {
+		storeProbe(fmt.Sprintf("file%d", i), stat, MediaInfo{})
+	}
+	cachedProbe("file0", stat)
+	storeProbe("new", stat, MediaInfo{})
+
+	if n := len(probeCache.entries); n != maxProbeCacheEntries {
+		t.Errorf("缓存条目数 %d, 期望 %d", n, maxProbeCacheEntries)
+	}
+	for key, want := range map[string]bool{"file0": true, "file1": false, "new": true} {
+		if _, ok := probeCache.entries[key]; ok != want {
+			t.Errorf("%s: 是否缓存 %v, 期望 %v", key, ok, want)
+		}
+	}
+
+	// 文件变化后删除过期条目
+	if err := os.WriteFile(path, []byte("changed media"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cachedProbe("new", changed); ok {
+		t.Error("文件变化后仍使用缓存")
+	}
+	if _, ok := probeCache.entries["new"]; ok {
+		t.Error("过期条目未删除")
+	}
+}
+
+func TestProbeDisplayResolution(t *testing.T) {
+	useFakeRunner(t, fakeScript{match: "-show_streams", stdout: probePortraitJSON})
+
+	// 编码分辨率 1920x1080, 旋转 90 度后显示为竖屏
+	source := filepath.Join(t.TempDir(), "portrait.mp4")
+	writeTestFile(t, source)
+
+	width, height, err := probeDisplayResolution(source)
+	if err != nil {
+		t.Fatalf("探测失败: %v", err)
+	}
+	if width != 1080 || height != 1920 {
+		t.Errorf("显示分辨率: 得到 %dx%d, 期望 1080x1920", width, height)
+	}
+}
+
+func TestProbeDisplayResolutionExif(t *testing.T) {
+	useFakeRunner(t, fakeScript{match: "-show_streams", stdout: probeImageJSON})
+
+	// EXIF 方向 6 (顺时针旋转 90 度) 的 640x480 照片摆正后为 480x640
+	dir := t.TempDir()
+	tests := []struct {
+		name       string
+		data       []byte
+		wantWidth  int
+		wantHeight int
+	}{
+		{"rotated.jpg", buildExifJPEG(6), 480, 640},
+		{"flipped.jpg", buildExifJPEG(3), 640, 480},
+		{"plain.png", []byte("fake media"), 640, 480},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, tt.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		width, height, err := probeDisplayResolution(path)
+		if err != nil {
+			t.Fatalf("%s: 探测失败: %v", tt.name, err)
+		}
+		if width != tt.wantWidth || height != tt.wantHeight {
+			t.Errorf("%s: 得到 %dx%d, 期望 %dx%d", tt.name, width, height, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// defaultMaxConcurrentTasks 未配置时同时运行的 FFmpeg 任务数
+const defaultMaxConcurrentTasks = 2
+
+// taskQueue 全局任务队列, 所有处理任务经由队列启动
+var taskQueue = &TaskQueue{workers: defaultMaxConcurrentTasks}
+
+// TaskQueue 限制并发数的 FFmpeg 任务队列, 按提交顺序 (FIFO) 启动任务
+type TaskQueue struct {
+	mutex   sync.Mutex
+	workers int           // 最大并发任务数
+	running int           // 正在运行的任务数
+	pending []*FFmpegTask // 等待启动的任务
+}
+
+// SetWorkers 设置最大并发任务数, 小于 1 时使用默认值
+func (q *TaskQueue) SetWorkers(n int) {
+	if n < 1 {
+		n = defaultMaxConcurrentTasks
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.workers = n
+	q.dispatch()
+}
+
+// Enqueue 将任务加入队列, 有空闲名额时立即启动
+func (q *TaskQueue) Enqueue(task *FFmpegTask) {
+	task.Mutex.Lock()
+	task.Status.Status = "queued"
+	task.Status.UpdatedAt = time.Now()
+	task.queue = q
+	task.Mutex.Unlock()
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.pending = append(q.pending, task)
+	q.dispatch()
+}
+
+// Remove 将仍在排队的任务移出队列, 任务已经开始启动时返回 false
+func (q *TaskQueue) Remove(task *FFmpegTask) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, pending := range q.pending {
+		if pending == task {
+			q.pending = append(q.pending[:i:i], q.pending[i+1:]...)
+			task.Mutex.Lock()
+			task.Status.QueuePosition = 0
+			task.Mutex.Unlock()
+			q.dispatch()
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch 在并发数允许时按顺序启动排队的任务, 并更新剩余任务的排队位置; 调用方需持有 q.mutex
+func (q *TaskQueue) dispatch() {
+	for q.running < q.workers && len(q.pending) > 0 {
+		task := q.pending[0]
+		q.pending = q.pending[1:]
+		q.running++
+		go q.run(task)
+	}
+
+	for i, task := range q.pending {
+		task.Mutex.Lock()
+		task.Status.QueuePosition = i + 1
+		task.Mutex.Unlock()
+	}
+}
+
+// run 启动任务并等待其结束, 然后释放名额
+func (q *TaskQueue) run(task *FFmpegTask) {
+	task.Mutex.Lock()
+	task.Status.QueuePosition = 0
+	stopped := task.stopped
+	task.Mutex.Unlock()
+
+	// 出队后、启动前被停止的任务不再启动
+	if stopped {
+		task.finish()
+	} else if err := task.Start(); err != nil {
+		slog.Error("FFmpeg任务启动失败", "taskID", task.ID, "error", err)
+	}
+	<-task.DoneChan
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.running--
+	q.dispatch()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// taskState 返回任务当前的状态和排队位置
+func taskState(task *FFmpegTask) (string, int) {
+	task.Mutex.Lock()
+	defer task.Mutex.Unlock()
+	return task.Status.Status, task.Status.QueuePosition
+}
+
+// waitState 等待任务进入指定状态和排队位置, 超时返回 false
+func waitState(task *FFmpegTask, want string, wantPosition int) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, position := taskState(task)
+		if status == want && position == wantPosition {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitIdle 等待队列中没有运行和排队的任务
+func waitIdle(t *testing.T, q *TaskQueue) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		q.mutex.Lock()
+		running, pending := q.running, len(q.pending)
+		q.mutex.Unlock()
+		if running == 0 && pending == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("队列未释放: 运行中 %d, 排队 %d", running, pending)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTaskQueue(t *testing.T) {
+	useFakeRunner(t,
+		fakeScript{match: "format=duration", stdout: "10.000000\n"},
+		fakeScript{match: "-progress pipe:1", stdout: fakeProgress(1), block: true},
+	)
+
+	req := ProcessRequest{
+		SourcePath:     "in.mp4",
+		OutputPath:     "out.mp4",
+		WatermarkLayer: WatermarkLayer{WatermarkPath: "logo.png", Scale: 100, Opacity: 100},
+	}
+
+	q := &TaskQueue{}
+	q.SetWorkers(2)
+
+	tasks := make([]*FFmpegTask, 4)
+	for i := range tasks {
+		task, err := NewFFmpegTask(req)
+		if err != nil {
+			t.Fatalf("创建FFmpeg任务失败: %v", err)
+		}
+		tasks[i] = task
+		q.Enqueue(task)
+	}
+
+	// check 验证每个任务的状态和排队位置
+	check := func(step string, want ...string) {
+		t.Helper()
+		position := 0
+		for i, task := range tasks {
+			wantPosition := 0
+			if want[i] == "queued" {
+				position++
+				wantPosition = position
+			}
+			if !waitState(task, want[i], wantPosition) {
+				status, got := taskState(task)
+				t.Errorf("%s: 任务 %d 状态 %s 位置 %d, 期望 %s 位置 %d", step, i+1, status, got, want[i], wantPosition)
+			}
+		}
+	}
+
+	check("提交后", "processing", "processing", "queued", "queued")
+
+	// 结束第二个任务后, 按提交顺序启动第三个任务
+	tasks[1].Stop()
+	waitTask(t, tasks[1])
+	check("第二个任务结束", "processing", "failed", "processing", "queued")
+
+	// 增加并发数时立即启动排队的任务
+	q.SetWorkers(3)
+	check("增加并发数", "processing", "failed", "processing", "processing")
+
+	for _, task := range []*FFmpegTask{tasks[0], tasks[2], tasks[3]} {
+		task.Stop()
+		waitTask(t, task)
+	}
+	waitIdle(t, q)
+}
+
+func TestTaskQueueStartFailure(t *testing.T) {
+	useFakeRunner(t, fakeScript{match: "format=duration", stdout: "10.000000\n"})
+
+	req := ProcessRequest{
+		SourcePath:     "in.mp4",
+		OutputPath:     "out.mp4",
+		WatermarkLayer: WatermarkLayer{WatermarkPath: "logo.png", Scale: 100, Opacity: 100},
+	}
+	task, err := NewFFmpegTask(req)
+	if err != nil {
+		t.Fatalf("创建FFmpeg任务失败: %v", err)
+	}
+
+	// 任务已经启动过, 再次启动失败时也要释放名额并通知等待者
+	task.Cmd.Start()
+
+	q := &TaskQueue{}
+	q.SetWorkers(1)
+	q.Enqueue(task)
+
+	if status := waitTask(t, task); status.Status != "failed" {
+		t.Errorf("启动失败的任务: 状态 %s, 期望 failed", status.Status)
+	}
+
+	waitIdle(t, q)
+}
+
+func TestTaskQueueStopQueued(t *testing.T) {
+	useFakeRunner(t,
+		fakeScript{match: "format=duration", stdout: "10.000000\n"},
+		fakeScript{match: "-progress pipe:1", stdout: fakeProgress(1), block: true},
+	)
+
+	req := ProcessRequest{
+		SourcePath:     "in.mp4",
+		OutputPath:     "out.mp4",
+		WatermarkLayer: WatermarkLayer{WatermarkPath: "logo.png", Scale: 100, Opacity: 100},
+	}
+
+	q := &TaskQueue{}
+	q.SetWorkers(1)
+
+	tasks := make([]*FFmpegTask, 3)
+	for i := range tasks {
+		task, err := NewFFmpegTask(req)
+		if err != nil {
+			t.Fatalf("创建FFmpeg任务失败: %v", err)
+		}
+		tasks[i] = task
+		q.Enqueue(task)
+	}
+	if !waitState(tasks[0], "processing", 0) || !waitState(tasks[2], "queued", 2) {
+		t.Fatalf("提交后: 任务未按顺序排队")
+	}
+
+	// 停止排队中的任务: 移出队列并通知等待者, 后面的任务前移
+	if err := tasks[1].Stop(); err != nil {
+		t.Fatalf("停止排队任务失败: %v", err)
+	}
+	if status := waitTask(t, tasks[1]); status.Status != "failed" || status.Error != "Task stopped by user" {
+		t.Errorf("停止排队任务: 状态 %s 错误 %q", status.Status, status.Error)
+	}
+	if !waitState(tasks[2], "queued", 1) {
+		status, position := taskState(tasks[2])
+		t.Errorf("停止排队任务后: 任务 3 状态 %s 位置 %d, 期望 queued 位置 1", status, position)
+	}
+
+	// 释放名额后只启动第三个任务, 被停止的任务保持失败状态
+	tasks[0].Stop()
+	waitTask(t, tasks[0])
+	if !waitState(tasks[2], "processing", 0) {
+		status, position := taskState(tasks[2])
+		t.Errorf("第一个任务结束后: 任务 3 状态 %s 位置 %d, 期望 processing", status, position)
+	}
+	if status, _ := taskState(tasks[1]); status != "failed" {
+		t.Errorf("被停止的任务重新启动: 状态 %s", status)
+	}
+
+	tasks[2].Stop()
+	waitTask(t, tasks[2])
+	waitIdle(t, q)
+
+	// 被停止的任务从未启动 FFmpeg
+	process := tasks[1].Cmd.(*fakeProcess)
+	process.mutex.Lock()
+	started := process.started
+	process.mutex.Unlock()
+	if started {
+		t.Errorf("被停止的排队任务启动了 FFmpeg")
+	}
+}
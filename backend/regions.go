@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"ffwatermark/filtergraph"
+)
+
+// 区域去除方式
+const (
+	regionDelogo   = "delogo"   // 用周围像素插值填充
+	regionBlur     = "blur"     // 方框模糊
+	regionPixelate = "pixelate" // 马赛克
+)
+
+// 模糊半径和马赛克块大小的默认值
+const (
+	defaultBlurRadius = 10
+	defaultPixelSize  = 16
+)
+
+// validateRegions 校验需要去除的区域
+func validateRegions(regions []RemovalRegion) error {
+	for i, r := range regions {
+		if err := validateRegion(r); err != nil {
+			return fmt.Errorf("region %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validateRegion 校验单个区域
+func validateRegion(r RemovalRegion) error {
+	switch r.Method {
+	case "", regionDelogo, regionBlur, regionPixelate:
+	default:
+		return fmt.Errorf("invalid method: %q", r.Method)
+	}
+
+	if r.X < 0 || r.Y < 0 || r.Width <= 0 || r.Height <= 0 {
+		return fmt.Errorf("invalid rectangle: %dx%d+%d+%d", r.Width, r.Height, r.X, r.Y)
+	}
+	if r.Strength < 0 {
+		return fmt.Errorf("invalid strength: %d", r.Strength)
+	}
+	if r.Start < 0 || r.End < 0 || (r.End != 0 && r.End <= r.Start) {
+		return fmt.Errorf("invalid time range: %v-%v", r.Start, r.End)
+	}
+
+	return nil
+}
+
+// buildRegionChains 构建依次处理所有区域的滤镜链, 输入为 main, 最后的输出标签为 out (为空时不加标签)
+func buildRegionChains(regions []RemovalRegion, main, out string) *filtergraph.Graph {
+	var g filtergraph.Graph
+
+	for i, r := range regions {
+		n := i + 1
+		input := main
+		main = fmt.Sprintf("r%d", n)
+		if n == len(regions) {
+			main = out
+		}
+
+		var last *filtergraph.Chain
+		switch r.Method {
+		case regionBlur, regionPixelate:
+			// 裁剪出区域处理后叠加回原位置
+			base, crop, fill := fmt.Sprintf("rbase%d", n), fmt.Sprintf("rcrop%d", n), fmt.Sprintf("rfill%d", n)
+			g.Chain(input).Then(filtergraph.New("split")).To(base, crop)
+			g.Chain(crop).Then(filtergraph.New("crop", strconv.Itoa(r.Width), strconv.Itoa(r.Height), strconv.Itoa(r.X), strconv.Itoa(r.Y))).
+				Then(regionFilter(r)...).
+				To(fill)
+			last = g.Chain(base, fill).Then(filtergraph.New("overlay").Set("x", r.X).Set("y", r.Y))
+		default:
+			last = g.Chain(input).Then(filtergraph.New("delogo").Set("x", r.X).Set("y", r.Y).Set("w", r.Width).Set("h", r.Height))
+		}
+
+		// 只在指定时间段内处理
+		if r.Start > 0 || r.End > 0 {
+			last.Filters[len(last.Filters)-1].Set("enable", buildEnableExpr(VisibilityOptions{Intervals: []TimeRange{{Start: r.Start, End: r.End}}}))
+		}
+		if main != "" {
+			last.To(main)
+		}
+	}
+
+	return &g
+}
+
+// regionFilter 返回模糊或马赛克处理裁剪区域的滤镜
+func regionFilter(r RemovalRegion) []*filtergraph.Filter {
+	if r.Method == regionPixelate {
+		size := r.Strength
+		if size == 0 {
+			size = defaultPixelSize
+		}
+		// 先缩小再用最近邻放大回原尺寸
+		return []*filtergraph.Filter{
+			filtergraph.New("scale").Set("w", fmt.Sprintf("max(1,iw/%d)", size)).Set("h", fmt.Sprintf("max(1,ih/%d)", size)),
+			filtergraph.New("scale", strconv.Itoa(r.Width), strconv.Itoa(r.Height)).Set("flags", "neighbor"),
+		}
+	}
+
+	radius := r.Strength
+	if radius == 0 {
+		radius = defaultBlurRadius
+	}
+	// 模糊半径不能超过平面宽高的一半, 小区域时自动减小
+	return []*filtergraph.Filter{
+		filtergraph.New("boxblur").
+			Set("luma_radius", fmt.Sprintf("min(%d,min(w,h)/2)", radius)).
+			Set("chroma_radius", fmt.Sprintf("min(%d,min(cw,ch)/2)", radius)),
+	}
+}
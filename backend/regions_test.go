@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildRegionChains(t *testing.T) {
+	tests := []struct {
+		name    string
+		regions []RemovalRegion
+		out     string
+		want    []string
+	}{
+		{
+			"delogo",
+			[]RemovalRegion{{X: 20, Y: 10, Width: 120, Height: 40}},
+			"clean",
+			[]string{"[0]delogo=x=20:y=10:w=120:h=40[clean]"},
+		},
+		{
+			"模糊区域带时间段",
+			[]RemovalRegion{{X: 0, Y: 600, Width: 300, Height: 80, Method: "blur", Start: 5, End: 12}},
+			"clean",
+			[]string{
+				"[0]split[rbase1][rcrop1]",
+				"[rcrop1]crop=300:80:0:600,boxblur=luma_radius='min(10,min(w,h)/2)':chroma_radius='min(10,min(cw,ch)/2)'[rfill1]",
+				"[rbase1][rfill1]overlay=x=0:y=600:enable='between(t,5,12)'[clean]",
+			},
+		},
+		{
+			"多个区域, 最后不加标签",
+			[]RemovalRegion{
+				{X: 1, Y: 2, Width: 30, Height: 40, Start: 3},
+				{X: 50, Y: 60, Width: 64, Height: 32, Method: "pixelate", Strength: 8},
+			},
+			"",
+			[]string{
+				"[0]delogo=x=1:y=2:w=30:h=40:enable='gte(t,3)'[r1]",
+				"[r1]split[rbase2][rcrop2]",
+				"[rcrop2]crop=64:32:50:60,scale=w='max(1,iw/8)':h='max(1,ih/8)',scale=64:32:flags=neighbor[rfill2]",
+				"[rbase2][rfill2]overlay=x=50:y=60",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got := buildRegionChains(tt.regions, "0", tt.out).String()
+		if want := strings.Join(tt.want, ";"); got != want {
+			t.Errorf("%s:\n得到 %q\n期望 %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestBuildOverlayFilterRegions(t *testing.T) {
+	req := ProcessRequest{
+		WatermarkLayer: WatermarkLayer{WatermarkPath: "logo.png", Position: "top-left", Scale: 100, Opacity: 100},
+		Regions:        []RemovalRegion{{X: 10, Y: 10, Width: 100, Height: 50}},
+	}
+
+	want := "[0]delogo=x=10:y=10:w=100:h=50[clean];[1]scale=iw*100/100:-1[wm1];[clean][wm1]overlay=x=0:y=0"
+	if got := buildOverlayFilter(req); got != want {
+		t.Errorf("区域去除滤镜错误:\n得到 %q\n期望 %q", got, want)
+	}
+}
+
+func TestValidateRegions(t *testing.T) {
+	tests := []struct {
+		name    string
+		region  RemovalRegion
+		wantErr bool
+	}{
+		{"delogo", RemovalRegion{X: 0, Y: 0, Width: 10, Height: 10}, false},
+		{"马赛克", RemovalRegion{Width: 10, Height: 10, Method: "pixelate", Strength: 4}, false},
+		{"未知方式", RemovalRegion{Width: 10, Height: 10, Method: "inpaint"}, true},
+		{"尺寸无效", RemovalRegion{Width: 0, Height: 10}, true},
+		{"坐标为负", RemovalRegion{X: -1, Width: 10, Height: 10}, true},
+		{"时间段无效", RemovalRegion{Width: 10, Height: 10, Start: 5, End: 2}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateRegions([]RemovalRegion{tt.region}); (err != nil) != tt.wantErr {
+			t.Errorf("%s: 错误 %v, 期望返回错误 %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"os/exec"
+)
+
+// Runner 创建外部进程 (FFmpeg、ffprobe), 测试中替换为不依赖真实 FFmpeg 的实现
+type Runner interface {
+	Command(name string, args ...string) Process
+}
+
+// Process 外部进程, 方法与 exec.Cmd 一致
+type Process interface {
+	StdinPipe() (io.WriteCloser, error)
+	StdoutPipe() (io.ReadCloser, error)
+	StderrPipe() (io.ReadCloser, error)
+	SetStderr(w io.Writer) // 将 stderr 写入 w, 需要在 Start 之前调用
+	Start() error
+	Wait() error
+	Run() error
+	Output() ([]byte, error)
+	Kill() error // 结束进程, 未启动时什么也不做
+	String() string
+}
+
+// runner 全局进程创建器
+var runner Runner = execRunner{}
+
+// execRunner 使用 os/exec 创建真实进程
+type execRunner struct{}
+
+// Command 创建进程
+func (execRunner) Command(name string, args ...string) Process {
+	return &execProcess{exec.Command(name, args...)}
+}
+
+// execProcess 包装 exec.Cmd
+type execProcess struct {
+	*exec.Cmd
+}
+
+// SetStderr 设置 stderr 输出
+func (p *execProcess) SetStderr(w io.Writer) {
+	p.Stderr = w
+}
+
+// Kill 结束进程
+func (p *execProcess) Kill() error {
+	if p.Process == nil {
+		return nil
+	}
+	return p.Process.Kill()
+}
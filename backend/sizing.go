@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"ffwatermark/filtergraph"
+)
+
+// 水印尺寸模式
+const (
+	sizeWatermark = "watermark" // Scale 为水印自身尺寸的百分比
+	sizeWidth     = "width"     // Scale 为主画面宽度的百分比, 水印宽度随画面变化
+	sizeHeight    = "height"    // Scale 为主画面高度的百分比, 水印高度随画面变化
+)
+
+// isRelativeSize 判断水印尺寸是否相对于主画面
+func isRelativeSize(layer WatermarkLayer) bool {
+	return layer.SizeMode == sizeWidth || layer.SizeMode == sizeHeight
+}
+
+// validateSizing 校验水印尺寸设置
+func validateSizing(layer WatermarkLayer) error {
+	switch layer.SizeMode {
+	case "", sizeWatermark:
+	case sizeWidth, sizeHeight:
+		if layer.Scale > 100 {
+			return fmt.Errorf("invalid scale for %s size mode: %d", layer.SizeMode, layer.Scale)
+		}
+	default:
+		return fmt.Errorf("invalid size mode: %q", layer.SizeMode)
+	}
+
+	if layer.MinSize < 0 || layer.MaxSize < 0 {
+		return fmt.Errorf("invalid size limits: %d-%d", layer.MinSize, layer.MaxSize)
+	}
+	if layer.MinSize > 0 && layer.MaxSize > 0 && layer.MinSize > layer.MaxSize {
+		return fmt.Errorf("min size %d is greater than max size %d", layer.MinSize, layer.MaxSize)
+	}
+
+	// 最小/最大像素尺寸只限制相对主画面的尺寸, 不能在 watermark 模式下被静默忽略
+	if !isRelativeSize(layer) && (layer.MinSize > 0 || layer.MaxSize > 0) {
+		return fmt.Errorf("min/max size requires width or height size mode")
+	}
+
+	return nil
+}
+
+// clampExpr 用最小/最大像素值限制尺寸表达式
+func clampExpr(expr string, minSize, maxSize int) string {
+	switch {
+	case minSize > 0 && maxSize > 0:
+		return fmt.Sprintf("clip(%s,%d,%d)", expr, minSize, maxSize)
+	case minSize > 0:
+		return fmt.Sprintf("max(%s,%d)", expr, minSize)
+	case maxSize > 0:
+		return fmt.Sprintf("min(%s,%d)", expr, maxSize)
+	}
+	return expr
+}
+
+// scale2refFilter 构建相对主画面缩放水印的 scale2ref 滤镜, 保持水印宽高比
+//
+// scale2ref 中 main_w/main_h 为参考画面 (主画面) 尺寸, a 为水印的宽高比
+func scale2refFilter(layer WatermarkLayer) *filtergraph.Filter {
+	if layer.SizeMode == sizeHeight {
+		h := clampExpr(fmt.Sprintf("main_h*%d/100", layer.Scale), layer.MinSize, layer.MaxSize)
+		return filtergraph.New("scale2ref").Set("w", "oh*a").Set("h", h)
+	}
+
+	w := clampExpr(fmt.Sprintf("main_w*%d/100", layer.Scale), layer.MinSize, layer.MaxSize)
+	return filtergraph.New("scale2ref").Set("w", w).Set("h", "ow/a")
+}
+
+// relativeSize 计算水印相对主画面缩放后的像素尺寸, 用于在 Go 中预先生成的水印图层
+func relativeSize(layer WatermarkLayer, frameW, frameH, wmW, wmH int) (int, int) {
+	clamp := func(v float64) float64 {
+		if layer.MinSize > 0 {
+			v = math.Max(v, float64(layer.MinSize))
+		}
+		if layer.MaxSize > 0 {
+			v = math.Min(v, float64(layer.MaxSize))
+		}
+		return math.Max(1, math.Round(v))
+	}
+
+	aspect := float64(wmW) / float64(wmH)
+	if layer.SizeMode == sizeHeight {
+		h := clamp(float64(frameH*layer.Scale) / 100)
+		return int(math.Max(1, math.Round(h*aspect))), int(h)
+	}
+
+	w := clamp(float64(frameW*layer.Scale) / 100)
+	return int(w), int(math.Max(1, math.Round(w/aspect)))
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestBuildLayerFilterRelativeSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		layer WatermarkLayer
+		want  string
+	}{
+		{
+			"width with clamps",
+			WatermarkLayer{WatermarkPath: "logo.png", Position: "top-right", Scale: 15, Opacity: 100, SizeMode: "width", MinSize: 64, MaxSize: 480},
+			"[1][0]scale2ref=w='clip(main_w*15/100,64,480)':h=ow/a[wm1][ref1];[ref1][wm1]overlay=x=main_w-overlay_w:y=0",
+		},
+		{
+			"height of animated watermark",
+			WatermarkLayer{WatermarkPath: "sting.gif", Scale: 10, Opacity: 100, SizeMode: "height"},
+			"[1]setpts=PTS-STARTPTS[wmsrc1];[wmsrc1][0]scale2ref=w=oh*a:h=main_h*10/100[wm1][ref1];" +
+				"[ref1][wm1]overlay=x=0:y=0:shortest=1",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := buildLayerFilter(tt.layer, 1, "0", "").String(); got != tt.want {
+			t.Errorf("%s:\n得到 %q\n期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRelativeSize(t *testing.T) {
+	layer := WatermarkLayer{Scale: 10, SizeMode: "width", MinSize: 100}
+
+	// 4K 画面: 3840 * 10% = 384
+	if w, h := relativeSize(layer, 3840, 2160, 200, 100); w != 384 || h != 192 {
+		t.Errorf("4K 尺寸错误: %dx%d", w, h)
+	}
+
+	// 480p 画面: 854 * 10% = 85, 受最小尺寸限制为 100
+	if w, h := relativeSize(layer, 854, 480, 200, 100); w != 100 || h != 50 {
+		t.Errorf("480p 尺寸错误: %dx%d", w, h)
+	}
+}
+
+func TestValidateSizing(t *testing.T) {
+	tests := []struct {
+		name    string
+		layer   WatermarkLayer
+		wantErr bool
+	}{
+		{"default", WatermarkLayer{Scale: 150}, false},
+		{"width", WatermarkLayer{Scale: 20, SizeMode: "width", MinSize: 50, MaxSize: 300}, false},
+		{"width over 100%", WatermarkLayer{Scale: 150, SizeMode: "width"}, true},
+		{"unknown mode", WatermarkLayer{Scale: 20, SizeMode: "diagonal"}, true},
+		{"min greater than max", WatermarkLayer{Scale: 20, SizeMode: "height", MinSize: 300, MaxSize: 100}, true},
+		{"limits in watermark mode", WatermarkLayer{Scale: 50, MaxSize: 300}, true},
+		{"limits in explicit watermark mode", WatermarkLayer{Scale: 50, SizeMode: "watermark", MinSize: 50}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateSizing(tt.layer); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr = %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"ffwatermark/filtergraph"
+)
+
+// isImageFile 判断文件是否为图片
+func isImageFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".bmp":
+		return true
+	}
+	return false
+}
+
+// isStillImage 判断源文件是否按静态图片处理 (GIF 可能是动图, 按视频处理)
+func isStillImage(path string) bool {
+	return isImageFile(path) && strings.ToLower(filepath.Ext(path)) != ".gif"
+}
+
+// validateImageOptions 校验图片输出设置
+func validateImageOptions(opts ImageOptions) error {
+	if opts.JPEGQuality < 0 || opts.JPEGQuality > 100 {
+		return fmt.Errorf("invalid jpeg quality: %d", opts.JPEGQuality)
+	}
+	if opts.PNGCompression < 0 || opts.PNGCompression > 9 {
+		return fmt.Errorf("invalid png compression level: %d", opts.PNGCompression)
+	}
+	return nil
+}
+
+// buildImageArgs 构建静态图片的输出参数
+func buildImageArgs(opts ImageOptions, outputPath string) []string {
+	// 只输出一帧, 没有音频
+	args := []string{"-frames:v", "1", "-update", "1"}
+
+	switch strings.ToLower(filepath.Ext(outputPath)) {
+	case ".jpg", ".jpeg":
+		if opts.JPEGQuality > 0 {
+			args = append(args, "-q:v", strconv.Itoa(jpegQScale(opts.JPEGQuality)))
+		}
+	case ".png":
+		if opts.PNGCompression > 0 {
+			args = append(args, "-compression_level", strconv.Itoa(opts.PNGCompression))
+		}
+	}
+
+	return args
+}
+
+// jpegQScale 将 1-100 的 JPEG 质量转换为 FFmpeg 的 qscale (31 最差, 2 最好)
+func jpegQScale(quality int) int {
+	return int(math.Round(31 - float64(quality-1)*29/99))
+}
+
+// sourceFilter 返回源画面在叠加水印前需要的预处理滤镜, 目前用于按 EXIF 方向摆正图片
+func sourceFilter(sourcePath string) []*filtergraph.Filter {
+	if !isStillImage(sourcePath) {
+		return nil
+	}
+
+	orientation, err := readExifOrientation(sourcePath)
+	if err != nil {
+		// PNG/BMP 等格式没有 EXIF, 按原方向处理
+		slog.Debug("未读取到 EXIF 方向", "path", sourcePath, "error", err)
+		return nil
+	}
+
+	return orientationFilter(orientation)
+}
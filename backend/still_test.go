@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// buildExifJPEG 构造只包含 EXIF 方向信息的最小 JPEG 数据
+func buildExifJPEG(orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // 大端字节序, IFD0 偏移 8
+		0x00, 0x01, // 1 个条目
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, orientation, 0x00, 0x00, // Orientation, SHORT
+		0x00, 0x00, 0x00, 0x00, // 没有下一个 IFD
+	}
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	size := len(app1) + 2
+
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1, byte(size >> 8), byte(size)}
+	data = append(data, app1...)
+	return append(data, 0xFF, 0xDA, 0x00, 0x02)
+}
+
+func TestParseExifOrientation(t *testing.T) {
+	orientation, err := parseExifOrientation(bytes.NewReader(buildExifJPEG(6)))
+	if err != nil {
+		t.Fatalf("解析 EXIF 失败: %v", err)
+	}
+	if orientation != 6 {
+		t.Errorf("方向错误: 期望 6, 得到 %d", orientation)
+	}
+	if filter := joinFilters(orientationFilter(orientation)); filter != "transpose=1" {
+		t.Errorf("方向滤镜错误: %q", filter)
+	}
+
+	// 没有 EXIF 的 JPEG
+	orientation, err = parseExifOrientation(bytes.NewReader([]byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}))
+	if err != nil || orientation != 1 {
+		t.Errorf("无 EXIF 时应返回 1, 得到 %d, %v", orientation, err)
+	}
+}
+
+func TestBuildFFmpegArgsStillImage(t *testing.T) {
+	req := ProcessRequest{
+		SourcePath:     "photo.jpg",
+		OutputPath:     "photo_marked.jpg",
+		WatermarkLayer: WatermarkLayer{WatermarkPath: "wm.png", Scale: 100, Opacity: 100},
+		Image:          ImageOptions{JPEGQuality: 100},
+	}
+
+	args := strings.Join(buildFFmpegArgs(req), " ")
+	if !strings.Contains(args, "-noautorotate -i photo.jpg") {
+		t.Errorf("图片输入参数错误: %s", args)
+	}
+	if !strings.Contains(args, "-frames:v 1 -update 1 -q:v 2") {
+		t.Errorf("图片输出参数错误: %s", args)
+	}
+	if strings.Contains(args, "-codec:a") {
+		t.Errorf("图片不应包含音频参数: %s", args)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// renderTiledWatermark 将水印图片按网格平铺并整体旋转, 生成与视频分辨率一致的水印图层
+func renderTiledWatermark(wm image.Image, opts TileOptions, width, height int) (*image.RGBA, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid watermark size: %dx%d", width, height)
+	}
+	if opts.SpacingX < 0 || opts.SpacingY < 0 {
+		return nil, fmt.Errorf("invalid tile spacing: %dx%d", opts.SpacingX, opts.SpacingY)
+	}
+
+	wb := wm.Bounds()
+	if wb.Empty() {
+		return nil, fmt.Errorf("watermark image is empty")
+	}
+	cellW := wb.Dx() + opts.SpacingX
+	cellH := wb.Dy() + opts.SpacingY
+
+	// 在足够大的图层上平铺, 避免旋转后四角缺失
+	side := int(math.Ceil(math.Hypot(float64(width), float64(height))))
+	layer := image.NewRGBA(image.Rect(0, 0, side, side))
+
+	for row := 0; row*cellH < side; row++ {
+		// 砖块排列: 每行向右错位 RowOffset, 并从左侧补齐
+		offset := (row*opts.RowOffset)%cellW - cellW
+		for x := offset; x < side; x += cellW {
+			pt := image.Pt(x, row*cellH)
+			xdraw.Draw(layer, wb.Sub(wb.Min).Add(pt), wm, wb.Min, xdraw.Over)
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	drawRotated(dst, layer, opts.Angle)
+
+	return dst, nil
+}
+
+// newTilePath 返回临时目录中新的平铺图层路径
+func newTilePath() string {
+	return filepath.Join(AppConfig.TempPath, fmt.Sprintf("tile_%d.png", time.Now().UnixNano()))
+}
+
+// prepareTiledWatermark 为平铺模式生成全画面水印图层, 并将水印层的图片路径替换为生成的图层
+func prepareTiledWatermark(sourcePath string, layer *WatermarkLayer) error {
+	if layer.Mode != "tile" {
+		return nil
+	}
+
+	width, height, err := probeDisplayResolution(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	// 读取水印图片
+	file, err := os.Open(layer.WatermarkPath)
+	if err != nil {
+		return fmt.Errorf("failed to open watermark: %v", err)
+	}
+	defer file.Close()
+
+	wm, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode watermark: %v", err)
+	}
+
+	// 按缩放比例调整单个水印大小
+	if isRelativeSize(*layer) || (layer.Scale > 0 && layer.Scale != 100) {
+		wb := wm.Bounds()
+		w := int(math.Max(1, math.Round(float64(wb.Dx()*layer.Scale)/100)))
+		h := int(math.Max(1, math.Round(float64(wb.Dy()*layer.Scale)/100)))
+		if isRelativeSize(*layer) {
+			w, h = relativeSize(*layer, width, height, wb.Dx(), wb.Dy())
+		}
+		scaled := image.NewRGBA(image.Rect(0, 0, w, h))
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), wm, wb, xdraw.Src, nil)
+		wm = scaled
+	}
+
+	tiled, err := renderTiledWatermark(wm, layer.Tile, width, height)
+	if err != nil {
+		return err
+	}
+
+	// 保存平铺图层到临时目录
+	if err := os.MkdirAll(AppConfig.TempPath, 0755); err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	tilePath := newTilePath()
+	out, err := os.Create(tilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create tile image: %v", err)
+	}
+	defer out.Close()
+
+	if err := png.Encode(out, tiled); err != nil {
+		return fmt.Errorf("failed to encode tile image: %v", err)
+	}
+
+	layer.WatermarkPath = tilePath
+	return nil
+}
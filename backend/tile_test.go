@@ -0,0 +1,108 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// probeRotatedSmallJSON 编码为 64x36、旋转 90 度的小尺寸视频, 显示为 36x64
+const probeRotatedSmallJSON = `{
+	"streams": [
+		{
+			"index": 0,
+			"codec_name": "h264",
+			"codec_type": "video",
+			"width": 64,
+			"height": 36,
+			"r_frame_rate": "25/1",
+			"side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]
+		}
+	],
+	"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "4.000000"}
+}`
+
+func TestRenderTiledWatermark(t *testing.T) {
+	// 10x10 的不透明水印, 间距 10 像素, 每行错位 5 像素
+	wm := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(wm, wm.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	opts := TileOptions{SpacingX: 10, SpacingY: 10, RowOffset: 5}
+	img, err := renderTiledWatermark(wm, opts, 100, 60)
+	if err != nil {
+		t.Fatalf("平铺水印失败: %v", err)
+	}
+
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 60 {
+		t.Fatalf("尺寸错误: 期望 100x60, 得到 %dx%d", b.Dx(), b.Dy())
+	}
+
+	// 平铺图层居中于更大的画布上, 通过像素的相对位置验证网格
+	y := -1
+	for py := 0; py < 60 && y < 0; py++ {
+		if img.RGBAAt(50, py).A == 255 {
+			y = py
+		}
+	}
+	if y < 0 {
+		t.Fatal("未找到水印像素")
+	}
+	for x := 0; x+20 < 100; x++ {
+		if img.RGBAAt(x, y).A != img.RGBAAt(x+20, y).A {
+			t.Fatalf("水平方向平铺周期错误: x=%d", x)
+		}
+	}
+
+	// 下一行水印应向右错位 5 像素
+	for x := 0; x+5 < 100; x++ {
+		if img.RGBAAt(x, y).A != img.RGBAAt(x+5, y+20).A {
+			t.Fatalf("行错位错误: x=%d", x)
+		}
+	}
+}
+
+func TestRenderTiledWatermarkInvalid(t *testing.T) {
+	wm := image.NewRGBA(image.Rect(0, 0, 10, 10))
+
+	if _, err := renderTiledWatermark(wm, TileOptions{SpacingX: -1}, 100, 100); err == nil {
+		t.Error("负数间距应返回错误")
+	}
+	if _, err := renderTiledWatermark(image.NewRGBA(image.Rect(0, 0, 0, 0)), TileOptions{}, 100, 100); err == nil {
+		t.Error("空水印应返回错误")
+	}
+}
+
+func TestPrepareTiledWatermarkRotatedSource(t *testing.T) {
+	useFakeRunner(t, fakeScript{match: "-show_streams", stdout: probeRotatedSmallJSON})
+	AppConfig.TempPath = t.TempDir()
+	t.Cleanup(func() { AppConfig.TempPath = "" })
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "portrait.mp4")
+	writeTestFile(t, source)
+
+	logo := filepath.Join(dir, "logo.png")
+	file, err := os.Create(logo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(file, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	file.Close()
+
+	// 平铺图层与自动旋转后的画面等大
+	layer := WatermarkLayer{WatermarkPath: logo, Mode: "tile", Scale: 100}
+	if err := prepareTiledWatermark(source, &layer); err != nil {
+		t.Fatalf("生成平铺图层失败: %v", err)
+	}
+	tiled, err := loadImage(layer.WatermarkPath)
+	if err != nil {
+		t.Fatalf("读取平铺图层失败: %v", err)
+	}
+	if b := tiled.Bounds(); b.Dx() != 36 || b.Dy() != 64 {
+		t.Errorf("平铺图层尺寸: 得到 %dx%d, 期望 36x64", b.Dx(), b.Dy())
+	}
+}
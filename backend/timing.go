@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateVisibility 校验水印显示时间设置
+func validateVisibility(v VisibilityOptions) error {
+	for _, r := range v.Intervals {
+		// 同号时可以直接比较, 异号需要视频时长, 在 resolveVisibility 中校验
+		if r.End != 0 && (r.Start < 0) == (r.End < 0) && r.End <= r.Start {
+			return fmt.Errorf("invalid time range: %v-%v", r.Start, r.End)
+		}
+	}
+
+	if v.Every < 0 || v.Duration < 0 {
+		return fmt.Errorf("invalid periodic visibility: every %v for %v", v.Every, v.Duration)
+	}
+	if (v.Every > 0) != (v.Duration > 0) || v.Duration > v.Every {
+		return fmt.Errorf("invalid periodic visibility: every %v for %v", v.Every, v.Duration)
+	}
+
+	return nil
+}
+
+// hasRelativeTimes 判断是否存在相对于视频结尾的时间 (负数)
+func hasRelativeTimes(v VisibilityOptions) bool {
+	for _, r := range v.Intervals {
+		if r.Start < 0 || r.End < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveVisibility 将相对于视频结尾的时间转换为绝对时间
+func resolveVisibility(v *VisibilityOptions, duration float64) error {
+	if !hasRelativeTimes(*v) {
+		return nil
+	}
+	if duration <= 0 {
+		return fmt.Errorf("source duration is unknown, cannot resolve times relative to the end")
+	}
+
+	for i, r := range v.Intervals {
+		// 早于视频开头的时间无法表示 (End 为 0 表示直到结尾), 视为无效请求
+		if r.Start < 0 {
+			r.Start = duration + r.Start
+		}
+		if r.End < 0 {
+			r.End = duration + r.End
+			if r.End <= 0 {
+				return &requestError{fmt.Errorf("time range %v-%v ends before the start of the %vs source", v.Intervals[i].Start, v.Intervals[i].End, duration)}
+			}
+		}
+		if r.Start < 0 {
+			return &requestError{fmt.Errorf("time range %v-%v starts before the start of the %vs source", v.Intervals[i].Start, v.Intervals[i].End, duration)}
+		}
+		if r.End != 0 && r.End <= r.Start {
+			return &requestError{fmt.Errorf("invalid time range: %v-%v", v.Intervals[i].Start, v.Intervals[i].End)}
+		}
+		v.Intervals[i] = r
+	}
+
+	return nil
+}
+
+// buildEnableExpr 构建 overlay 的 enable 表达式, 不限制显示时间时返回空字符串
+func buildEnableExpr(v VisibilityOptions) string {
+	var conditions []string
+
+	// 多个时间段之间为 "或" 关系
+	if len(v.Intervals) > 0 {
+		ranges := make([]string, 0, len(v.Intervals))
+		for _, r := range v.Intervals {
+			if r.End == 0 {
+				ranges = append(ranges, fmt.Sprintf("gte(t,%s)", formatNumber(r.Start)))
+			} else {
+				ranges = append(ranges, fmt.Sprintf("between(t,%s,%s)", formatNumber(r.Start), formatNumber(r.End)))
+			}
+		}
+		if len(ranges) == 1 {
+			conditions = append(conditions, ranges[0])
+		} else {
+			conditions = append(conditions, "("+strings.Join(ranges, "+")+")")
+		}
+	}
+
+	// 周期显示: 每 Every 秒显示 Duration 秒
+	if v.Every > 0 {
+		conditions = append(conditions, fmt.Sprintf("lt(mod(t,%s),%s)", formatNumber(v.Every), formatNumber(v.Duration)))
+	}
+
+	// 时间段与周期显示同时设置时为 "且" 关系
+	return strings.Join(conditions, "*")
+}
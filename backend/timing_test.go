@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestBuildEnableExpr(t *testing.T) {
+	tests := []struct {
+		name string
+		v    VisibilityOptions
+		want string
+	}{
+		{"always", VisibilityOptions{}, ""},
+		{"single range", VisibilityOptions{Intervals: []TimeRange{{Start: 5, End: 15.5}}}, "between(t,5,15.5)"},
+		{"open end", VisibilityOptions{Intervals: []TimeRange{{Start: 30}}}, "gte(t,30)"},
+		{
+			"multiple ranges",
+			VisibilityOptions{Intervals: []TimeRange{{Start: 0, End: 10}, {Start: 50, End: 60}}},
+			"(between(t,0,10)+between(t,50,60))",
+		},
+		{"periodic", VisibilityOptions{Every: 30, Duration: 5}, "lt(mod(t,30),5)"},
+		{
+			"range and periodic",
+			VisibilityOptions{Intervals: []TimeRange{{Start: 10, End: 100}}, Every: 20, Duration: 4},
+			"between(t,10,100)*lt(mod(t,20),4)",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := buildEnableExpr(tt.v); got != tt.want {
+			t.Errorf("%s: 得到 %q, 期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveVisibility(t *testing.T) {
+	// 预告片首尾各 10 秒
+	v := VisibilityOptions{Intervals: []TimeRange{{Start: 0, End: 10}, {Start: -10}}}
+	if err := resolveVisibility(&v, 95); err != nil {
+		t.Fatalf("转换失败: %v", err)
+	}
+	if v.Intervals[1].Start != 85 || v.Intervals[1].End != 0 {
+		t.Errorf("结尾时间段转换错误: %+v", v.Intervals[1])
+	}
+
+	if err := resolveVisibility(&VisibilityOptions{Intervals: []TimeRange{{Start: -10}}}, 0); err == nil {
+		t.Error("时长未知时应返回错误")
+	}
+	if err := resolveVisibility(&VisibilityOptions{Intervals: []TimeRange{{Start: 50, End: -60}}}, 100); err == nil {
+		t.Error("结束时间早于开始时间时应返回错误")
+	}
+
+	// 早于视频开头的时间不能变成 "直到结尾" 或从 0 开始
+	for _, r := range []TimeRange{{Start: 0, End: -200}, {Start: 0, End: -95}, {Start: -200, End: 10}} {
+		if err := resolveVisibility(&VisibilityOptions{Intervals: []TimeRange{r}}, 95); err == nil {
+			t.Errorf("%v-%v: 超出视频开头时应返回错误", r.Start, r.End)
+		}
+	}
+}
+
+func TestValidateVisibility(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       VisibilityOptions
+		wantErr bool
+	}{
+		{"empty", VisibilityOptions{}, false},
+		{"reversed range", VisibilityOptions{Intervals: []TimeRange{{Start: 10, End: 5}}}, true},
+		{"relative range", VisibilityOptions{Intervals: []TimeRange{{Start: -20, End: -10}}}, false},
+		{"every without duration", VisibilityOptions{Every: 10}, true},
+		{"duration longer than period", VisibilityOptions{Every: 10, Duration: 15}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateVisibility(tt.v); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr = %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"ffwatermark/filtergraph"
+)
+
+// validateTransform 校验水印旋转和透视设置
+func validateTransform(layer WatermarkLayer) error {
+	if math.IsNaN(layer.Rotation) || math.IsInf(layer.Rotation, 0) {
+		return fmt.Errorf("invalid rotation: %v", layer.Rotation)
+	}
+	if len(layer.Perspective) != 0 && len(layer.Perspective) != 8 {
+		return fmt.Errorf("perspective requires 8 values, got %d", len(layer.Perspective))
+	}
+	if layer.Mode == "tile" && (layer.Rotation != 0 || len(layer.Perspective) > 0) {
+		return fmt.Errorf("use tile.angle to rotate tiled watermarks")
+	}
+	return nil
+}
+
+// transformFilters 返回在缩放之后对水印做透视变换和旋转的滤镜, 没有变换时返回空
+func transformFilters(layer WatermarkLayer) []*filtergraph.Filter {
+	var filters []*filtergraph.Filter
+
+	if len(layer.Perspective) == 8 {
+		// 透视变换会用边缘像素填充空白区域, 先补一圈透明像素使填充区域保持透明
+		filters = append(filters,
+			filtergraph.New("format", "rgba"),
+			filtergraph.New("pad").Set("w", "iw+2").Set("h", "ih+2").Set("x", 1).Set("y", 1).Set("color", "black@0"),
+		)
+
+		// 四个角 (左上、右上、左下、右下) 的目标坐标, 单位为水印宽高的百分比
+		perspective := filtergraph.New("perspective")
+		for i := 0; i < 4; i++ {
+			perspective.
+				Set(fmt.Sprintf("x%d", i), "W*"+formatNumber(layer.Perspective[i*2])+"/100").
+				Set(fmt.Sprintf("y%d", i), "H*"+formatNumber(layer.Perspective[i*2+1])+"/100")
+		}
+		filters = append(filters, perspective.Set("sense", "destination"))
+	}
+
+	if layer.Rotation != 0 {
+		// 输出尺寸为旋转后的外接矩形, 空白区域填充透明色 (c=none 时四角像素未定义)
+		if len(filters) == 0 {
+			filters = append(filters, filtergraph.New("format", "rgba"))
+		}
+		filters = append(filters, filtergraph.New("rotate").
+			Set("a", formatNumber(layer.Rotation)+"*PI/180").
+			Set("ow", "rotw(a)").
+			Set("oh", "roth(a)").
+			Set("c", "black@0"))
+	}
+
+	return filters
+}
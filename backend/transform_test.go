@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransformFilters(t *testing.T) {
+	tests := []struct {
+		name  string
+		layer WatermarkLayer
+		want  string
+	}{
+		{"无变换", WatermarkLayer{}, ""},
+		{"旋转", WatermarkLayer{Rotation: -15}, "format=rgba,rotate=a=-15*PI/180:ow=rotw(a):oh=roth(a):c=black@0"},
+		{
+			"透视",
+			WatermarkLayer{Perspective: []float64{10, 0, 90, 0, 0, 100, 100, 100}},
+			"format=rgba,pad=w=iw+2:h=ih+2:x=1:y=1:color=black@0," +
+				"perspective=x0=W*10/100:y0=H*0/100:x1=W*90/100:y1=H*0/100:x2=W*0/100:y2=H*100/100:x3=W*100/100:y3=H*100/100:sense=destination",
+		},
+		{
+			"透视和旋转",
+			WatermarkLayer{Rotation: 30, Perspective: []float64{0, 0, 100, 10, 0, 100, 100, 90}},
+			"format=rgba,pad=w=iw+2:h=ih+2:x=1:y=1:color=black@0," +
+				"perspective=x0=W*0/100:y0=H*0/100:x1=W*100/100:y1=H*10/100:x2=W*0/100:y2=H*100/100:x3=W*100/100:y3=H*90/100:sense=destination," +
+				"rotate=a=30*PI/180:ow=rotw(a):oh=roth(a):c=black@0",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := joinFilters(transformFilters(tt.layer)); got != tt.want {
+			t.Errorf("%s: 得到 %q, 期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildLayerFilterRotation(t *testing.T) {
+	layer := WatermarkLayer{WatermarkPath: "logo.png", Position: "top-left", Scale: 50, Opacity: 100, Rotation: 45}
+
+	want := "[1]scale=iw*50/100:-1,format=rgba,rotate=a=45*PI/180:ow=rotw(a):oh=roth(a):c=black@0[wm1];[0][wm1]overlay=x=0:y=0[v1]"
+	if got := buildLayerFilter(layer, 1, "0", "v1").String(); got != want {
+		t.Errorf("旋转滤镜错误:\n得到 %q\n期望 %q", got, want)
+	}
+}
+
+func TestValidateTransform(t *testing.T) {
+	tests := []struct {
+		name    string
+		layer   WatermarkLayer
+		wantErr bool
+	}{
+		{"无变换", WatermarkLayer{}, false},
+		{"旋转", WatermarkLayer{Rotation: 90}, false},
+		{"透视", WatermarkLayer{Perspective: make([]float64, 8)}, false},
+		{"透视坐标数量错误", WatermarkLayer{Perspective: []float64{0, 0, 100, 0}}, true},
+		{"平铺模式旋转", WatermarkLayer{Mode: "tile", Rotation: 30}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateTransform(tt.layer); (err != nil) != tt.wantErr {
+			t.Errorf("%s: 错误 %v, 期望返回错误 %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTransformFiltersRotateFill(t *testing.T) {
+	// 旋转后的空白区域必须是透明色, 不能是未定义的像素
+	filters := transformFilters(WatermarkLayer{Rotation: 45})
+	if len(filters) == 0 {
+		t.Fatal("旋转时没有生成滤镜")
+	}
+	if got, want := filters[len(filters)-1].String(), "c=black@0"; !strings.HasSuffix(got, want) {
+		t.Errorf("旋转滤镜: 得到 %q, 期望以 %q 结尾", got, want)
+	}
+}
@@ -16,25 +16,283 @@ type WatermarkRequest struct {
 	ImageData string `json:"imageData"` // Base64 编码的水印图片数据
 }
 
+// WatermarkSettings 文字水印设置 (与前端 canvas.ts 中的 WatermarkSettings 一致)
+type WatermarkSettings struct {
+	Text              string  `json:"text"`              // 水印文本
+	FontSize          float64 `json:"fontSize"`          // 字号 (像素)
+	FontFamily        string  `json:"fontFamily"`        // 字体名称
+	Opacity           float64 `json:"opacity"`           // 不透明度 (0-1)
+	Angle             float64 `json:"angle"`             // 旋转角度 (度)
+	Color             string  `json:"color"`             // 文字颜色 (e.g., "#000000")
+	HorizontalDensity int     `json:"horizontalDensity"` // 水平方向水印数量
+	VerticalDensity   int     `json:"verticalDensity"`   // 垂直方向水印数量
+}
+
+// TextWatermarkRequest 文字水印渲染请求
+type TextWatermarkRequest struct {
+	SourcePath string            `json:"sourcePath"` // 源文件路径, 用于获取输出分辨率
+	Width      int               `json:"width"`      // 输出宽度, 为 0 时使用源文件分辨率
+	Height     int               `json:"height"`     // 输出高度, 为 0 时使用源文件分辨率
+	Settings   WatermarkSettings `json:"settings"`   // 水印设置
+}
+
 // ProcessRequest 媒体处理请求
+//
+// 顶层的水印字段描述单个水印; 设置 Layers 时忽略顶层水印字段, 按顺序叠加所有水印层
 type ProcessRequest struct {
-	SourcePath     string `json:"sourcePath"`     // 源文件路径
-	OutputPath     string `json:"outputPath"`     // 输出文件路径
-	WatermarkPath  string `json:"watermarkPath"`  // 水印图片路径
-	Position       string `json:"position"`       // 水印位置 (e.g., "center", "top-left")
-	Scale          int    `json:"scale"`          // 水印缩放比例 (百分比)
-	Opacity        int    `json:"opacity"`        // 水印透明度 (0-100)
+	SourcePath string `json:"sourcePath"` // 源文件路径
+	OutputPath string `json:"outputPath"` // 输出文件路径
+	WatermarkLayer
+	Layers   []WatermarkLayer `json:"layers"`   // 多个水印层, 按顺序叠加
+	Regions  []RemovalRegion  `json:"regions"`  // 叠加水印之前需要去除的区域 (e.g., 第三方台标)
+	Encoding EncodingOptions  `json:"encoding"` // 输出编码设置, 未设置的项使用 FFmpeg 默认值
+	Image    ImageOptions     `json:"image"`    // 静态图片输出设置, 源文件为图片时使用
+}
+
+// RemovalRegion 需要去除的画面区域, 坐标和尺寸为源画面像素
+type RemovalRegion struct {
+	X        int     `json:"x"`        // 区域左上角横坐标
+	Y        int     `json:"y"`        // 区域左上角纵坐标
+	Width    int     `json:"width"`    // 区域宽度
+	Height   int     `json:"height"`   // 区域高度
+	Method   string  `json:"method"`   // 去除方式 ("delogo" 插值填充, "blur" 模糊, "pixelate" 马赛克), 默认为 "delogo"
+	Strength int     `json:"strength"` // 模糊半径或马赛克块大小 (像素), 0 表示使用默认值
+	Start    float64 `json:"start"`    // 开始时间 (秒)
+	End      float64 `json:"end"`      // 结束时间 (秒), 0 表示到结尾
+}
+
+// ImageOptions 静态图片输出设置
+type ImageOptions struct {
+	JPEGQuality    int `json:"jpegQuality"`    // JPEG 质量 (1-100), 0 表示使用默认值
+	PNGCompression int `json:"pngCompression"` // PNG 压缩级别 (1-9), 0 表示使用默认值
+}
+
+// EncodingOptions 输出编码设置
+type EncodingOptions struct {
+	VideoCodec  string `json:"videoCodec"`  // 视频编码器 (e.g., "libx264", "libx265", "libvpx-vp9")
+	CRF         int    `json:"crf"`         // 恒定质量因子, 0 表示不设置, 与 Bitrate 二选一
+	Bitrate     string `json:"bitrate"`     // 目标视频码率 (e.g., "2500k", "4M")
+	Preset      string `json:"preset"`      // 编码预设 (e.g., "veryfast", "slow")
+	PixelFormat string `json:"pixelFormat"` // 像素格式 (e.g., "yuv420p")
+	MaxWidth    int    `json:"maxWidth"`    // 最大输出宽度, 超出时等比缩小, 0 表示不限制
+	MaxHeight   int    `json:"maxHeight"`   // 最大输出高度, 超出时等比缩小, 0 表示不限制
+	AudioCodec  string `json:"audioCodec"`  // 音频编码器, 默认为 "copy"
+	FastStart   bool   `json:"fastStart"`   // 是否添加 -movflags +faststart, 便于网络播放
+	Container   string `json:"container"`   // 强制输出容器格式 (e.g., "mp4", "matroska"), 默认由扩展名决定
+}
+
+// WatermarkLayer 水印层设置
+type WatermarkLayer struct {
+	WatermarkPath string            `json:"watermarkPath"` // 水印图片路径, 支持 GIF/APNG 动图和 WebM/MOV 等视频
+	Position      string            `json:"position"`      // 水印位置, 九宫格锚点 (e.g., "center", "top-left", "bottom")
+	OffsetX       float64           `json:"offsetX"`       // 相对锚点的水平偏移, 正值向右
+	OffsetY       float64           `json:"offsetY"`       // 相对锚点的垂直偏移, 正值向下
+	Margin        float64           `json:"margin"`        // 水印与画面边缘的距离
+	Unit          string            `json:"unit"`          // 偏移量和边距的单位 ("px" 像素, "%" 画面尺寸百分比), 默认为 "px"
+	Scale         int               `json:"scale"`         // 水印缩放比例 (百分比), 含义由 SizeMode 决定
+	SizeMode      string            `json:"sizeMode"`      // 尺寸模式 ("watermark" 相对水印自身, "width"/"height" 相对主画面宽/高), 默认为 "watermark"
+	MinSize       int               `json:"minSize"`       // 相对主画面缩放时的最小像素尺寸, 0 表示不限制
+	MaxSize       int               `json:"maxSize"`       // 相对主画面缩放时的最大像素尺寸, 0 表示不限制
+	Opacity       int               `json:"opacity"`       // 水印不透明度 (0-100), 通过缩放水印透明通道实现
+	Blend         string            `json:"blend"`         // 混合模式 ("normal", "multiply", "screen", "overlay", "softlight"), 默认为 "normal"
+	Mode          string            `json:"mode"`          // 水印模式 ("single" 单个水印, "tile" 平铺水印, "invisible" 不可见取证水印), 默认为 "single"
+	Tile          TileOptions       `json:"tile"`          // 平铺模式设置
+	Motion        MotionOptions     `json:"motion"`        // 水印运动设置
+	Visibility    VisibilityOptions `json:"visibility"`    // 水印显示时间设置, 默认全程显示
+	Playback      string            `json:"playback"`      // 动图/视频水印的播放方式 ("loop" 循环播放, "once" 只播放一次), 默认为 "loop"
+	Effects       EffectOptions     `json:"effects"`       // 淡入淡出和脉冲效果设置
+	Rotation      float64           `json:"rotation"`      // 水印旋转角度 (度, 顺时针), 在缩放之后进行
+	Perspective   []float64         `json:"perspective"`   // 透视变换后四个角 (左上、右上、左下、右下) 的 x,y 坐标, 单位为水印宽高的百分比, 共 8 个值
+	Payload       string            `json:"payload"`       // 不可见水印的载荷, 最多 16 位十六进制数 (e.g., 接收者 ID)
+	Strength      float64           `json:"strength"`      // 不可见水印的嵌入强度, 0 表示使用默认值, 越大越稳健但越可能被察觉
+}
+
+// EffectOptions 水印透明度效果设置
+type EffectOptions struct {
+	FadeIn          float64 `json:"fadeIn"`          // 淡入时长 (秒), 从水印第一次出现时开始
+	FadeOut         float64 `json:"fadeOut"`         // 淡出时长 (秒), 在水印最后一次消失时结束
+	FadeOutEnd      float64 `json:"fadeOutEnd"`      // 淡出结束时间 (秒), 为 0 时使用最后的显示时间或视频结尾
+	PulsePeriod     float64 `json:"pulsePeriod"`     // 脉冲周期 (秒), 0 表示不启用
+	PulseMinOpacity int     `json:"pulseMinOpacity"` // 脉冲时的最低不透明度 (0-100, 相对于 Opacity)
+}
+
+// TimeRange 时间段 (秒), 负数表示相对于视频结尾的时间
+type TimeRange struct {
+	Start float64 `json:"start"` // 开始时间
+	End   float64 `json:"end"`   // 结束时间, 为 0 表示直到视频结尾
+}
+
+// VisibilityOptions 水印显示时间设置
+type VisibilityOptions struct {
+	Intervals []TimeRange `json:"intervals"` // 显示的时间段, 为空表示不限制
+	Every     float64     `json:"every"`     // 周期显示: 每隔多少秒
+	Duration  float64     `json:"duration"`  // 周期显示: 每次显示多少秒
+}
+
+// MotionOptions 水印运动设置
+type MotionOptions struct {
+	Type      string  `json:"type"`      // 运动类型 ("none", "scroll", "bounce", "orbit"), 默认为 "none"
+	Speed     float64 `json:"speed"`     // 运动速度, scroll/bounce 为像素/秒, orbit 为度/秒
+	Direction string  `json:"direction"` // scroll 的滚动方向 ("left", "right", "up", "down")
+	RadiusX   float64 `json:"radiusX"`   // orbit 的水平半径 (画面宽度百分比, 0-50)
+	RadiusY   float64 `json:"radiusY"`   // orbit 的垂直半径 (画面高度百分比, 0-50)
+}
+
+// TileOptions 平铺水印设置
+type TileOptions struct {
+	SpacingX  int     `json:"spacingX"`  // 水平间距 (像素)
+	SpacingY  int     `json:"spacingY"`  // 垂直间距 (像素)
+	RowOffset int     `json:"rowOffset"` // 相邻行的水平错位 (像素), 用于砖块排列
+	Angle     float64 `json:"angle"`     // 整体旋转角度 (度)
 }
 
 // TaskStatus 任务状态
 type TaskStatus struct {
-	ID        string    `json:"id"`        // 任务ID
-	Status    string    `json:"status"`    // 状态 (pending, processing, completed, failed)
-	Progress  int       `json:"progress"`  // 进度 (0-100)
-	Error     string    `json:"error"`     // 错误信息
-	Output    []string  `json:"output"`    // 命令输出日志
-	CreatedAt time.Time `json:"createdAt"` // 创建时间
-	UpdatedAt time.Time `json:"updatedAt"` // 更新时间
+	ID            string    `json:"id"`            // 任务ID
+	Status        string    `json:"status"`        // 状态 (pending, queued, processing, completed, failed)
+	Progress      int       `json:"progress"`      // 进度 (0-100)
+	QueuePosition int       `json:"queuePosition"` // 排队位置 (从 1 开始), 不在排队时为 0
+	Duration      float64   `json:"duration"`      // 源文件总时长 (秒)
+	CurrentTime   float64   `json:"currentTime"`   // 已处理的媒体时长 (秒)
+	Frame         int64     `json:"frame"`         // 已处理帧数
+	FPS           float64   `json:"fps"`           // 处理帧率
+	Bitrate       string    `json:"bitrate"`       // 输出码率 (e.g., "1024.0kbits/s")
+	Speed         float64   `json:"speed"`         // 处理速度 (相对实时的倍数)
+	OutTime       string    `json:"outTime"`       // 已输出时长 (HH:MM:SS.micro)
+	TotalSize     int64     `json:"totalSize"`     // 已输出文件大小 (字节)
+	ETA           float64   `json:"eta"`           // 预计剩余时间 (秒)
+	Error         string    `json:"error"`         // 错误信息
+	Output        []string  `json:"output"`        // 命令输出日志
+	CreatedAt     time.Time `json:"createdAt"`     // 创建时间
+	UpdatedAt     time.Time `json:"updatedAt"`     // 更新时间
+}
+
+// VerifyRequest 水印校验请求
+type VerifyRequest struct {
+	SourcePath    string  `json:"sourcePath"`    // 待校验的媒体文件路径
+	WatermarkPath string  `json:"watermarkPath"` // 水印图片路径, 必须位于 watermarks 目录中
+	Interval      float64 `json:"interval"`      // 采样间隔 (秒), 默认为 1
+	Threshold     float64 `json:"threshold"`     // 判定为匹配的最低置信度 (0-1), 默认为 0.7
+	Scales        []int   `json:"scales"`        // 水印在画面中相对原图的缩放比例 (百分比), 依次尝试, 默认为 [100]
+}
+
+// FrameMatch 单帧的水印匹配结果, 位置和尺寸为源画面像素
+type FrameMatch struct {
+	Time       float64 `json:"time"`       // 采样时间 (秒)
+	X          int     `json:"x"`          // 水印左上角横坐标
+	Y          int     `json:"y"`          // 水印左上角纵坐标
+	Width      int     `json:"width"`      // 水印宽度
+	Height     int     `json:"height"`     // 水印高度
+	Scale      int     `json:"scale"`      // 匹配的缩放比例 (百分比)
+	Confidence float64 `json:"confidence"` // 置信度 (0-1), 即归一化互相关系数
+	Matched    bool    `json:"matched"`    // 置信度是否达到阈值
+}
+
+// VerifyStatus 水印校验任务状态
+type VerifyStatus struct {
+	TaskStatus
+	Detected bool         `json:"detected"` // 是否有任一采样帧匹配到水印
+	Matches  []FrameMatch `json:"matches"`  // 每个采样帧的最佳匹配结果
+}
+
+// Recipient 批量处理的接收者
+type Recipient struct {
+	ID    string `json:"id"`    // 接收者 ID
+	Name  string `json:"name"`  // 接收者名称
+	Email string `json:"email"` // 接收者邮箱
+}
+
+// BatchRequest 按接收者批量生成个性化输出的请求
+//
+// OutputTemplate 和 Text.Text 中可以使用 {id}、{name}、{email}、{index} 占位符
+type BatchRequest struct {
+	SourcePath     string            `json:"sourcePath"`     // 源文件路径
+	OutputTemplate string            `json:"outputTemplate"` // 输出路径模板 (e.g., "screeners/{index}_{name}.mp4")
+	ManifestPath   string            `json:"manifestPath"`   // 清单文件路径, 默认为输出目录下的 manifest.json
+	Recipients     []Recipient       `json:"recipients"`     // 接收者列表
+	RecipientsCSV  string            `json:"recipientsCsv"`  // CSV 格式的接收者列表, 表头包含 id、name、email 列, 与 Recipients 合并
+	Text           WatermarkSettings `json:"text"`           // 接收者文字水印设置, 渲染为与画面等大的水印层
+	Layers         []WatermarkLayer  `json:"layers"`         // 叠加在所有输出上的其他水印层, 位于接收者文字水印之下
+	Regions        []RemovalRegion   `json:"regions"`        // 叠加水印之前需要去除的区域
+	Encoding       EncodingOptions   `json:"encoding"`       // 输出编码设置
+	Image          ImageOptions      `json:"image"`          // 静态图片输出设置
+}
+
+// BatchItem 单个接收者的处理结果
+type BatchItem struct {
+	Index      int       `json:"index"`      // 接收者序号 (从 1 开始)
+	Recipient  Recipient `json:"recipient"`  // 接收者信息
+	OutputPath string    `json:"outputPath"` // 输出文件路径
+	TaskID     string    `json:"taskId"`     // 对应的处理任务ID
+	Status     string    `json:"status"`     // 状态 (pending, processing, completed, failed)
+	Error      string    `json:"error"`      // 错误信息
+	Size       int64     `json:"size"`       // 输出文件大小 (字节)
+	SHA256     string    `json:"sha256"`     // 输出文件的 SHA-256
+}
+
+// BatchStatus 批量任务状态, 同时作为清单文件的内容
+type BatchStatus struct {
+	ID           string      `json:"id"`           // 批量任务ID
+	Status       string      `json:"status"`       // 状态 (pending, processing, completed, failed)
+	Total        int         `json:"total"`        // 接收者总数
+	Completed    int         `json:"completed"`    // 已完成的输出数
+	Failed       int         `json:"failed"`       // 失败的输出数
+	ManifestPath string      `json:"manifestPath"` // 清单文件路径
+	Items        []BatchItem `json:"items"`        // 每个接收者的处理结果
+	Error        string      `json:"error"`        // 错误信息
+	CreatedAt    time.Time   `json:"createdAt"`    // 创建时间
+	UpdatedAt    time.Time   `json:"updatedAt"`    // 更新时间
+}
+
+// MediaInfo 媒体文件探测结果
+type MediaInfo struct {
+	Path     string        `json:"path"`     // 文件路径
+	Format   string        `json:"format"`   // 容器格式 (e.g., "mov,mp4,m4a,3gp,3g2,mj2")
+	Duration float64       `json:"duration"` // 时长 (秒), 静态图片为 0
+	BitRate  int64         `json:"bitRate"`  // 总码率 (bit/s)
+	Size     int64         `json:"size"`     // 文件大小 (字节)
+	Video    *VideoStream  `json:"video"`    // 第一个视频流, 没有视频流时为 null
+	Audio    []AudioStream `json:"audio"`    // 音频轨道
+}
+
+// VideoStream 视频流信息
+type VideoStream struct {
+	Index         int     `json:"index"`         // 流序号
+	Codec         string  `json:"codec"`         // 编码器名称 (e.g., "h264")
+	Width         int     `json:"width"`         // 编码宽度
+	Height        int     `json:"height"`        // 编码高度
+	DisplayWidth  int     `json:"displayWidth"`  // 旋转后的显示宽度
+	DisplayHeight int     `json:"displayHeight"` // 旋转后的显示高度
+	FrameRate     string  `json:"frameRate"`     // 帧率 (e.g., "30000/1001")
+	FPS           float64 `json:"fps"`           // 帧率数值
+	PixelFormat   string  `json:"pixelFormat"`   // 像素格式 (e.g., "yuv420p")
+	Rotation      int     `json:"rotation"`      // 顺时针旋转角度 (0, 90, 180, 270)
+	BitRate       int64   `json:"bitRate"`       // 码率 (bit/s)
+}
+
+// AudioStream 音频轨道信息
+type AudioStream struct {
+	Index         int    `json:"index"`         // 流序号
+	Codec         string `json:"codec"`         // 编码器名称 (e.g., "aac")
+	SampleRate    int    `json:"sampleRate"`    // 采样率 (Hz)
+	Channels      int    `json:"channels"`      // 声道数
+	ChannelLayout string `json:"channelLayout"` // 声道布局 (e.g., "stereo")
+	Language      string `json:"language"`      // 语言标签
+	BitRate       int64  `json:"bitRate"`       // 码率 (bit/s)
+}
+
+// FFmpegCapabilities FFmpeg 版本及支持的编码器、滤镜和硬件加速方式
+type FFmpegCapabilities struct {
+	Path          string   `json:"path"`          // FFmpeg 可执行文件路径
+	Source        string   `json:"source"`        // 路径来源 (config, env, bundled, path)
+	Reason        string   `json:"reason"`        // 选择该路径的原因
+	Version       string   `json:"version"`       // 版本号 (e.g., "6.1.1")
+	Configuration string   `json:"configuration"` // 编译配置
+	Encoders      []string `json:"encoders"`      // 编码器名称 (e.g., "libx264")
+	Codecs        []string `json:"codecs"`        // 可编码的编解码器名称 (e.g., "h264")
+	Decoders      []string `json:"decoders"`      // 解码器名称 (e.g., "libvpx-vp9")
+	Filters       []string `json:"filters"`       // 滤镜名称
+	HWAccels      []string `json:"hwaccels"`      // 硬件加速方式 (e.g., "cuda")
 }
 
 // APIResponse API 响应格式
@@ -42,4 +300,4 @@ type APIResponse struct {
 	Code    int         `json:"code"`    // 状态码
 	Message string      `json:"message"` // 消息
 	Data    interface{} `json:"data"`    // 数据
-}
\ No newline at end of file
+}
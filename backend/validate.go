@@ -0,0 +1,115 @@
+package main
+
+import "fmt"
+
+// requestError 只有在读取源文件信息后才能发现的无效请求参数 (e.g., 相对时间超出视频时长), 接口返回 400
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// validateProcessRequest 校验媒体处理请求参数, 并拒绝当前 FFmpeg 不支持的编码器、解码器和滤镜
+func validateProcessRequest(req ProcessRequest) error {
+	if err := validateProcessParams(req); err != nil {
+		return err
+	}
+	return checkCapabilities(req)
+}
+
+// validateProcessParams 校验媒体处理请求参数, 不检查 FFmpeg 能力
+func validateProcessParams(req ProcessRequest) error {
+	if req.SourcePath == "" {
+		return fmt.Errorf("source path is required")
+	}
+	if req.OutputPath == "" {
+		return fmt.Errorf("output path is required")
+	}
+
+	if err := validateEncoding(req.Encoding); err != nil {
+		return err
+	}
+
+	if err := validateImageOptions(req.Image); err != nil {
+		return err
+	}
+
+	if err := validateRegions(req.Regions); err != nil {
+		return err
+	}
+
+	// 单个水印时错误信息不带层号
+	if len(req.Layers) == 0 {
+		if err := validateLayer(req.WatermarkLayer); err != nil {
+			return err
+		}
+	}
+
+	for i, layer := range req.Layers {
+		if err := validateLayer(layer); err != nil {
+			return fmt.Errorf("layer %d: %v", i+1, err)
+		}
+	}
+
+	return validateInvisibleRequest(req)
+}
+
+// validateLayer 校验单个水印层的参数
+func validateLayer(layer WatermarkLayer) error {
+	if layer.Mode == modeInvisible {
+		return validateInvisible(layer)
+	}
+
+	if layer.WatermarkPath == "" {
+		return fmt.Errorf("watermark path is required")
+	}
+
+	switch layer.Mode {
+	case "", "single", "tile":
+	default:
+		return fmt.Errorf("invalid mode: %q", layer.Mode)
+	}
+
+	if layer.Scale <= 0 {
+		return fmt.Errorf("invalid scale: %d", layer.Scale)
+	}
+	if layer.Opacity < 0 || layer.Opacity > 100 {
+		return fmt.Errorf("invalid opacity: %d", layer.Opacity)
+	}
+
+	if err := validateSizing(layer); err != nil {
+		return err
+	}
+
+	if err := validateBlend(layer.Blend); err != nil {
+		return err
+	}
+
+	if err := validateEffects(layer.Effects); err != nil {
+		return err
+	}
+
+	if err := validateTransform(layer); err != nil {
+		return err
+	}
+
+	if err := validatePosition(layer); err != nil {
+		return err
+	}
+
+	if err := validateMotion(layer); err != nil {
+		return err
+	}
+
+	if err := validatePlayback(layer); err != nil {
+		return err
+	}
+
+	return validateVisibility(layer.Visibility)
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ffwatermark/filtergraph"
+	xdraw "golang.org/x/image/draw"
+)
+
+// 水印校验参数默认值
+const (
+	verifyHeight           = 360 // 分析帧高度, 较高分辨率的视频缩小后再匹配
+	defaultVerifyInterval  = 1.0 // 默认采样间隔 (秒)
+	defaultVerifyThreshold = 0.7 // 默认判定为匹配的最低置信度
+)
+
+// 校验任务管理器
+var (
+	verifyManager = struct {
+		tasks map[string]*VerifyTask
+		mutex sync.RWMutex
+	}{
+		tasks: make(map[string]*VerifyTask),
+	}
+)
+
+// VerifyTask 水印校验任务: 用 FFmpeg 按间隔采样帧, 在 Go 中用模板匹配查找水印
+type VerifyTask struct {
+	ID         string
+	Cmd        Process
+	Status     *VerifyStatus
+	FramesPipe io.ReadCloser
+	DoneChan   chan bool
+	Mutex      sync.Mutex
+
+	req       VerifyRequest
+	templates []*matchTemplate
+	width     int     // 分析帧宽度
+	height    int     // 分析帧高度
+	factor    float64 // 分析帧与源画面的尺寸比例
+}
+
+// validateVerifyRequest 校验水印校验请求参数
+func validateVerifyRequest(req VerifyRequest) error {
+	if req.SourcePath == "" {
+		return fmt.Errorf("source path is required")
+	}
+	if _, err := resolveWatermarkFile(req.WatermarkPath); err != nil {
+		return err
+	}
+	if req.Interval < 0 {
+		return fmt.Errorf("invalid interval: %v", req.Interval)
+	}
+	if req.Threshold < 0 || req.Threshold > 1 {
+		return fmt.Errorf("invalid threshold: %v", req.Threshold)
+	}
+	for _, scale := range req.Scales {
+		if scale <= 0 || scale > 1000 {
+			return fmt.Errorf("invalid scale: %d", scale)
+		}
+	}
+
+	// 拒绝当前 FFmpeg 不支持的采样滤镜
+	return checkVerifyCapabilities(req)
+}
+
+// resolveWatermarkFile 返回 watermarks 目录中的水印文件路径, 只接受该目录中的文件
+func resolveWatermarkFile(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("watermark path is required")
+	}
+
+	dir, err := filepath.Abs("watermarks")
+	if err != nil {
+		return "", err
+	}
+
+	// 只给出文件名时在 watermarks 目录中查找
+	if filepath.Base(path) == path {
+		path = filepath.Join(dir, path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("watermark must be in the watermarks directory: %q", path)
+	}
+
+	return abs, nil
+}
+
+// NewVerifyTask 创建水印校验任务
+func NewVerifyTask(req VerifyRequest) (*VerifyTask, error) {
+	taskID := fmt.Sprintf("verify_%d", time.Now().UnixNano())
+
+	if req.Interval == 0 {
+		req.Interval = defaultVerifyInterval
+	}
+	if req.Threshold == 0 {
+		req.Threshold = defaultVerifyThreshold
+	}
+	if len(req.Scales) == 0 {
+		req.Scales = []int{100}
+	}
+
+	// 加载水印模板
+	path, err := resolveWatermarkFile(req.WatermarkPath)
+	if err != nil {
+		return nil, err
+	}
+	watermark, err := loadImage(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load watermark: %v", err)
+	}
+
+	// 分析帧尺寸: 按比例缩小到 verifyHeight, 不放大
+	srcWidth, srcHeight, err := probeDisplayResolution(req.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	width, height := analysisSize(srcWidth, srcHeight)
+	factor := float64(height) / float64(srcHeight)
+
+	// 每个缩放比例一个模板, 模板尺寸与水印在分析帧中的尺寸一致
+	var templates []*matchTemplate
+	for _, scale := range req.Scales {
+		b := watermark.Bounds()
+		tw := int(math.Round(float64(b.Dx()) * float64(scale) / 100 * factor))
+		th := int(math.Round(float64(b.Dy()) * float64(scale) / 100 * factor))
+		if tw < 4 || th < 4 || tw > width || th > height {
+			return nil, fmt.Errorf("watermark size at scale %d%% does not fit the source", scale)
+		}
+		tmpl, err := newMatchTemplate(watermark, tw, th)
+		if err != nil {
+			return nil, err
+		}
+		tmpl.scale = scale
+		templates = append(templates, tmpl)
+	}
+
+	var duration float64
+	if duration, err = probeDuration(req.SourcePath); err != nil {
+		slog.Warn("无法获取媒体时长, 进度将不可用", "path", req.SourcePath, "error", err)
+	}
+
+	cmd := runner.Command(AppConfig.FFmpegPath, buildVerifyArgs(req, width, height)...)
+	framesPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %v", err)
+	}
+
+	status := &VerifyStatus{
+		TaskStatus: TaskStatus{
+			ID:        taskID,
+			Status:    "pending",
+			Duration:  duration,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		Matches: make([]FrameMatch, 0),
+	}
+
+	slog.Info("FFmpeg命令", "cmd", cmd.String())
+
+	return &VerifyTask{
+		ID:         taskID,
+		Cmd:        cmd,
+		Status:     status,
+		FramesPipe: framesPipe,
+		DoneChan:   make(chan bool),
+		req:        req,
+		templates:  templates,
+		width:      width,
+		height:     height,
+		factor:     factor,
+	}, nil
+}
+
+// loadImage 读取图片文件, 动图只使用第一帧
+func loadImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// analysisSize 计算分析帧尺寸, 高度不超过 verifyHeight, 保持宽高比
+func analysisSize(width, height int) (int, int) {
+	if height <= verifyHeight {
+		return width, height
+	}
+	return max(1, int(math.Round(float64(width)*verifyHeight/float64(height)))), verifyHeight
+}
+
+// buildVerifyGraph 构建采样滤镜: 每隔 Interval 秒取一帧, 缩放为分析尺寸并转换为灰度
+func buildVerifyGraph(req VerifyRequest, width, height int) *filtergraph.Graph {
+	var g filtergraph.Graph
+	g.Chain().Then(
+		filtergraph.New("fps", "1/"+formatNumber(req.Interval)),
+		filtergraph.New("scale", strconv.Itoa(width), strconv.Itoa(height)),
+		filtergraph.New("format", "gray"),
+	)
+	return &g
+}
+
+// buildVerifyArgs 构建采样命令参数: 每隔 Interval 秒取一帧, 缩放后以灰度原始帧输出到 stdout
+func buildVerifyArgs(req VerifyRequest, width, height int) []string {
+	g := buildVerifyGraph(req, width, height)
+
+	return []string{
+		"-v", "error",
+		"-i", req.SourcePath,
+		"-vf", g.String(),
+		"-an",
+		"-f", "rawvideo",
+		"pipe:1",
+	}
+}
+
+// Start 启动水印校验任务
+func (t *VerifyTask) Start() error {
+	t.Status.Status = "processing"
+	t.Status.UpdatedAt = time.Now()
+
+	var stderr strings.Builder
+	t.Cmd.SetStderr(&stderr)
+
+	if err := t.Cmd.Start(); err != nil {
+		t.Status.Status = "failed"
+		t.Status.Error = err.Error()
+		t.Status.UpdatedAt = time.Now()
+		return fmt.Errorf("failed to start ffmpeg: %v", err)
+	}
+
+	slog.Info("水印校验任务启动", "taskID", t.ID)
+
+	go func() {
+		analyzeErr := t.analyzeFrames()
+		if analyzeErr != nil {
+			t.Cmd.Kill()
+		}
+		err := t.Cmd.Wait()
+
+		t.Mutex.Lock()
+		defer t.Mutex.Unlock()
+
+		switch {
+		case analyzeErr != nil:
+			t.Status.Status = "failed"
+			t.Status.Error = analyzeErr.Error()
+		case err != nil:
+			t.Status.Status = "failed"
+			t.Status.Error = fmt.Sprintf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		default:
+			t.Status.Status = "completed"
+			t.Status.Progress = 100
+		}
+
+		t.Status.ETA = 0
+		t.Status.UpdatedAt = time.Now()
+		close(t.DoneChan)
+	}()
+
+	return nil
+}
+
+// analyzeFrames 逐帧读取采样结果并匹配水印, 更新进度和匹配结果
+func (t *VerifyTask) analyzeFrames() error {
+	started := time.Now()
+	buf := make([]byte, t.width*t.height)
+
+	for index := 0; ; index++ {
+		if _, err := io.ReadFull(t.FramesPipe, buf); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read frame: %v", err)
+		}
+
+		frame := newGrayPlane(buf, t.width, t.height)
+		match := t.matchFrame(frame)
+		match.Time = float64(index) * t.req.Interval
+
+		t.Mutex.Lock()
+		t.Status.Matches = append(t.Status.Matches, match)
+		if match.Matched {
+			t.Status.Detected = true
+		}
+		t.Status.Frame = int64(index + 1)
+		t.Status.CurrentTime = match.Time
+		t.Status.Progress = calcProgress(match.Time, t.Status.Duration)
+		if elapsed := time.Since(started).Seconds(); elapsed > 0 {
+			t.Status.Speed = match.Time / elapsed
+		}
+		t.Status.ETA = calcETA(match.Time, t.Status.Duration, t.Status.Speed)
+		t.Status.UpdatedAt = time.Now()
+		t.Mutex.Unlock()
+	}
+}
+
+// matchFrame 在一帧中查找所有缩放比例的模板, 返回置信度最高的结果 (源画面坐标)
+func (t *VerifyTask) matchFrame(frame *grayPlane) FrameMatch {
+	var best FrameMatch
+	for _, tmpl := range t.templates {
+		x, y, score := findTemplate(frame, tmpl)
+		if score <= best.Confidence && best.Width > 0 {
+			continue
+		}
+		best = FrameMatch{
+			X:          int(math.Round(float64(x) / t.factor)),
+			Y:          int(math.Round(float64(y) / t.factor)),
+			Width:      int(math.Round(float64(tmpl.w) / t.factor)),
+			Height:     int(math.Round(float64(tmpl.h) / t.factor)),
+			Scale:      tmpl.scale,
+			Confidence: math.Max(0, score),
+		}
+	}
+	best.Matched = best.Confidence >= t.req.Threshold
+	return best
+}
+
+// GetStatus 获取任务状态
+func (t *VerifyTask) GetStatus() VerifyStatus {
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+
+	// 返回副本, 避免序列化时与分析协程并发修改匹配结果
+	status := *t.Status
+	status.Matches = append([]FrameMatch(nil), t.Status.Matches...)
+	return status
+}
+
+// grayPlane 灰度图像, 像素值为 0-255 的浮点数
+type grayPlane struct {
+	w, h int
+	pix  []float64
+}
+
+// newGrayPlane 从 8 位灰度数据创建灰度图像
+func newGrayPlane(data []byte, width, height int) *grayPlane {
+	pix := make([]float64, width*height)
+	for i := range pix {
+		pix[i] = float64(data[i])
+	}
+	return &grayPlane{w: width, h: height, pix: pix}
+}
+
+// half 将灰度图像缩小一半 (2x2 平均), 用于由粗到细的搜索
+func (g *grayPlane) half() *grayPlane {
+	w, h := g.w/2, g.h/2
+	pix := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := 2*y*g.w + 2*x
+			pix[y*w+x] = (g.pix[i] + g.pix[i+1] + g.pix[i+g.w] + g.pix[i+g.w+1]) / 4
+		}
+	}
+	return &grayPlane{w: w, h: h, pix: pix}
+}
+
+// matchTemplate 带透明遮罩的匹配模板, 只比较水印中不透明的像素
+type matchTemplate struct {
+	w, h   int
+	scale  int
+	dx, dy []int     // 参与匹配的像素位置
+	values []float64 // 参与匹配的像素值, 已减去均值
+	norm   float64   // values 的 L2 范数
+	coarse *matchTemplate
+}
+
+// newMatchTemplate 将水印图片缩放到指定尺寸并创建模板, 尺寸足够大时同时创建粗搜索用的半尺寸模板
+func newMatchTemplate(img image.Image, width, height int) (*matchTemplate, error) {
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(rgba, rgba.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	t := &matchTemplate{w: width, h: height}
+	var sum float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := rgba.RGBAAt(x, y)
+			if c.A < 128 {
+				continue
+			}
+			// RGBA 为预乘透明度, 还原后计算亮度
+			a := float64(c.A)
+			luma := (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) * 255 / a
+			t.dx = append(t.dx, x)
+			t.dy = append(t.dy, y)
+			t.values = append(t.values, luma)
+			sum += luma
+		}
+	}
+	if len(t.values) == 0 {
+		return nil, fmt.Errorf("watermark is fully transparent")
+	}
+
+	mean := sum / float64(len(t.values))
+	for i := range t.values {
+		t.values[i] -= mean
+		t.norm += t.values[i] * t.values[i]
+	}
+	t.norm = math.Sqrt(t.norm)
+	if t.norm < 1e-6 {
+		return nil, fmt.Errorf("watermark has no detail to match")
+	}
+
+	if width >= 16 && height >= 16 {
+		// 粗模板没有细节时只做全尺寸搜索
+		t.coarse, _ = newMatchTemplate(img, width/2, height/2)
+	}
+
+	return t, nil
+}
+
+// score 计算模板在 (x, y) 处与画面的归一化互相关系数 (-1 到 1)
+func (t *matchTemplate) score(frame *grayPlane, x, y int) float64 {
+	var sumF, sumFF, sumFT float64
+	base := y*frame.w + x
+	for i, v := range t.values {
+		f := frame.pix[base+t.dy[i]*frame.w+t.dx[i]]
+		sumF += f
+		sumFF += f * f
+		sumFT += f * v
+	}
+
+	n := float64(len(t.values))
+	variance := sumFF - sumF*sumF/n
+	if variance < 1e-6 {
+		return 0
+	}
+	return sumFT / (math.Sqrt(variance) * t.norm)
+}
+
+// search 在指定范围内逐点搜索, 返回得分最高的位置
+func (t *matchTemplate) search(frame *grayPlane, x0, y0, x1, y1 int) (int, int, float64) {
+	x0, y0 = max(0, x0), max(0, y0)
+	x1, y1 = min(frame.w-t.w, x1), min(frame.h-t.h, y1)
+
+	bestX, bestY, best := x0, y0, math.Inf(-1)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if s := t.score(frame, x, y); s > best {
+				bestX, bestY, best = x, y, s
+			}
+		}
+	}
+	return bestX, bestY, best
+}
+
+// findTemplate 在画面中查找模板位置: 先在半尺寸画面上粗搜索, 再在原尺寸上细化
+func findTemplate(frame *grayPlane, t *matchTemplate) (int, int, float64) {
+	if t.coarse == nil {
+		return t.search(frame, 0, 0, frame.w, frame.h)
+	}
+
+	cx, cy, _ := t.coarse.search(frame.half(), 0, 0, frame.w, frame.h)
+	return t.search(frame, 2*cx-2, 2*cy-2, 2*cx+2, 2*cy+2)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testWatermark 生成带透明边缘的测试水印: 透明背景上随机的明暗色块
+func testWatermark(width, height int) *image.RGBA {
+	rng := rand.New(rand.NewSource(2))
+	blocks := make([]uint8, (width/4+1)*(height/4+1))
+	for i := range blocks {
+		blocks[i] = uint8(40 + rng.Intn(2)*190)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 2; y < height-2; y++ {
+		for x := 2; x < width-2; x++ {
+			v := blocks[(y/4)*(width/4+1)+x/4]
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+// testScene 生成随机噪声背景, 并将水印不透明部分画在 (x, y) 处
+func testScene(width, height int, wm *image.RGBA, x, y int) []byte {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, width*height)
+	for i := range data {
+		data[i] = uint8(rng.Intn(256))
+	}
+	if wm != nil {
+		b := wm.Bounds()
+		for dy := 0; dy < b.Dy(); dy++ {
+			for dx := 0; dx < b.Dx(); dx++ {
+				if c := wm.RGBAAt(dx, dy); c.A == 255 {
+					data[(y+dy)*width+x+dx] = c.R
+				}
+			}
+		}
+	}
+	return data
+}
+
+func TestFindTemplate(t *testing.T) {
+	const width, height = 200, 120
+	wm := testWatermark(40, 24)
+
+	tmpl, err := newMatchTemplate(wm, 40, 24)
+	if err != nil {
+		t.Fatalf("创建模板失败: %v", err)
+	}
+
+	frame := newGrayPlane(testScene(width, height, wm, 131, 57), width, height)
+	x, y, score := findTemplate(frame, tmpl)
+	if x != 131 || y != 57 {
+		t.Errorf("匹配位置错误: 得到 (%d, %d), 期望 (131, 57)", x, y)
+	}
+	if score < 0.95 {
+		t.Errorf("置信度过低: %v", score)
+	}
+
+	// 没有水印的画面置信度应较低
+	frame = newGrayPlane(testScene(width, height, nil, 0, 0), width, height)
+	if _, _, score := findTemplate(frame, tmpl); score > 0.5 {
+		t.Errorf("未包含水印的画面置信度过高: %v", score)
+	}
+}
+
+func TestNewMatchTemplate(t *testing.T) {
+	if _, err := newMatchTemplate(image.NewRGBA(image.Rect(0, 0, 20, 20)), 20, 20); err == nil {
+		t.Error("完全透明的水印应返回错误")
+	}
+
+	flat := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for i := range flat.Pix {
+		flat.Pix[i] = 255
+	}
+	if _, err := newMatchTemplate(flat, 20, 20); err == nil {
+		t.Error("纯色水印应返回错误")
+	}
+}
+
+func TestAnalysisSize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		wantW, wantH  int
+	}{
+		{640, 360, 640, 360},
+		{320, 240, 320, 240},
+		{1920, 1080, 640, 360},
+		{1080, 1920, 203, 360},
+	}
+
+	for _, tt := range tests {
+		w, h := analysisSize(tt.width, tt.height)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("%dx%d: 得到 %dx%d, 期望 %dx%d", tt.width, tt.height, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestBuildVerifyArgs(t *testing.T) {
+	req := VerifyRequest{SourcePath: "clip.mp4", Interval: 0.5}
+
+	want := "-v error -i clip.mp4 -vf fps=1/0.5,scale=640:360,format=gray -an -f rawvideo pipe:1"
+	if got := strings.Join(buildVerifyArgs(req, 640, 360), " "); got != want {
+		t.Errorf("采样参数错误:\n得到 %q\n期望 %q", got, want)
+	}
+}
+
+func TestValidateVerifyRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     VerifyRequest
+		wantErr bool
+	}{
+		{"文件名", VerifyRequest{SourcePath: "clip.mp4", WatermarkPath: "logo.png"}, false},
+		{"watermarks 目录", VerifyRequest{SourcePath: "clip.mp4", WatermarkPath: "watermarks/logo.png", Threshold: 0.8}, false},
+		{"缺少源文件", VerifyRequest{WatermarkPath: "logo.png"}, true},
+		{"其他目录", VerifyRequest{SourcePath: "clip.mp4", WatermarkPath: "/etc/logo.png"}, true},
+		{"目录穿越", VerifyRequest{SourcePath: "clip.mp4", WatermarkPath: "watermarks/../logo.png"}, true},
+		{"阈值超出范围", VerifyRequest{SourcePath: "clip.mp4", WatermarkPath: "logo.png", Threshold: 1.5}, true},
+		{"缩放比例无效", VerifyRequest{SourcePath: "clip.mp4", WatermarkPath: "logo.png", Scales: []int{0}}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateVerifyRequest(tt.req); (err != nil) != tt.wantErr {
+			t.Errorf("%s: 错误 %v, 期望返回错误 %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewVerifyTaskRotatedSource(t *testing.T) {
+	r := useFakeRunner(t,
+		fakeScript{match: "-show_streams", stdout: probePortraitJSON},
+		fakeScript{match: "format=duration", stdout: "12.345000\n"},
+	)
+
+	// 水印必须位于工作目录的 watermarks 目录中
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	if err := os.Mkdir("watermarks", 0755); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(filepath.Join("watermarks", "logo.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(file, testWatermark(64, 64))
+	file.Close()
+	writeTestFile(t, "portrait.mp4")
+
+	task, err := NewVerifyTask(VerifyRequest{SourcePath: "portrait.mp4", WatermarkPath: "watermarks/logo.png"})
+	if err != nil {
+		t.Fatalf("创建校验任务失败: %v", err)
+	}
+
+	// 自动旋转后的竖屏画面按显示分辨率 1080x1920 等比缩小, 不压扁成横屏
+	wantWidth, wantHeight := analysisSize(1080, 1920)
+	if task.width != wantWidth || task.height != wantHeight {
+		t.Errorf("分析帧尺寸: 得到 %dx%d, 期望 %dx%d", task.width, task.height, wantWidth, wantHeight)
+	}
+	commands := r.Commands()
+	if want := fmt.Sprintf("scale=%d:%d", wantWidth, wantHeight); !strings.Contains(commands[len(commands)-1], want) {
+		t.Errorf("采样命令: %s, 期望包含 %s", commands[len(commands)-1], want)
+	}
+}
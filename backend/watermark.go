@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/math/fixed"
+)
+
+// 自定义字体目录, 按 fontFamily 查找 <fontFamily>.ttf / <fontFamily>.otf
+const fontDir = "fonts"
+
+// renderTextWatermark 按照前端 drawWatermark 的规则渲染平铺、旋转的文字水印
+//
+// 输出为透明背景的 RGBA 图像, 尺寸与目标视频分辨率一致
+func renderTextWatermark(settings WatermarkSettings, width, height int) (*image.RGBA, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid watermark size: %dx%d", width, height)
+	}
+	if settings.Text == "" {
+		return nil, fmt.Errorf("watermark text is empty")
+	}
+	if settings.FontSize <= 0 {
+		return nil, fmt.Errorf("invalid font size: %v", settings.FontSize)
+	}
+	if settings.HorizontalDensity <= 0 || settings.VerticalDensity <= 0 {
+		return nil, fmt.Errorf("invalid watermark density: %dx%d", settings.HorizontalDensity, settings.VerticalDensity)
+	}
+
+	textColor, err := parseHexColor(settings.Color)
+	if err != nil {
+		return nil, err
+	}
+	textColor.A = uint8(math.Round(float64(textColor.A) * clamp01(settings.Opacity)))
+
+	face, err := loadFontFace(settings.FontFamily, settings.FontSize)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	// 在足够大的图层上绘制未旋转的水印网格, 避免旋转后四角缺失
+	side := int(math.Ceil(math.Hypot(float64(width), float64(height))))
+	offsetX := float64(side-width) / 2
+	offsetY := float64(side-height) / 2
+	layer := image.NewRGBA(image.Rect(0, 0, side, side))
+
+	drawer := &font.Drawer{
+		Dst:  layer,
+		Src:  image.NewUniform(textColor),
+		Face: face,
+	}
+
+	// 计算文本大小
+	textWidth := float64(drawer.MeasureString(settings.Text)) / 64
+	textHeight := settings.FontSize
+
+	// 计算水印间距
+	horizontalSpacing := float64(width) / float64(settings.HorizontalDensity)
+	verticalSpacing := float64(height) / float64(settings.VerticalDensity)
+
+	// 计算起始位置，使水印整体居中
+	startX := (float64(width) - float64(settings.HorizontalDensity-1)*horizontalSpacing - textWidth) / 2
+	startY := (float64(height) - float64(settings.VerticalDensity-1)*verticalSpacing) / 2
+
+	// 绘制水印网格
+	for i := 0; i < settings.VerticalDensity; i++ {
+		for j := 0; j < settings.HorizontalDensity; j++ {
+			x := offsetX + startX + float64(j)*horizontalSpacing
+			y := offsetY + startY + float64(i)*verticalSpacing + textHeight/2
+			drawer.Dot = fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)}
+			drawer.DrawString(settings.Text)
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	drawRotated(dst, layer, settings.Angle)
+
+	return dst, nil
+}
+
+// drawRotated 将图层绕中心旋转 angle 度后居中绘制到 dst 上
+func drawRotated(dst *image.RGBA, layer image.Image, angle float64) {
+	rad := angle * math.Pi / 180
+	sin, cos := math.Sincos(rad)
+	lb := layer.Bounds()
+	layerX := float64(lb.Min.X+lb.Max.X) / 2
+	layerY := float64(lb.Min.Y+lb.Max.Y) / 2
+	db := dst.Bounds()
+	centerX := float64(db.Min.X+db.Max.X) / 2
+	centerY := float64(db.Min.Y+db.Max.Y) / 2
+	transform := f64.Aff3{
+		cos, -sin, centerX - (cos*layerX - sin*layerY),
+		sin, cos, centerY - (sin*layerX + cos*layerY),
+	}
+
+	xdraw.BiLinear.Transform(dst, transform, layer, lb, xdraw.Over, nil)
+}
+
+// loadFontFace 加载字体, 找不到对应字体文件时使用内置的 Go Regular 字体
+func loadFontFace(family string, size float64) (font.Face, error) {
+	data := goregular.TTF
+	if family != "" && !strings.ContainsAny(family, `/\`) {
+		for _, ext := range []string{".ttf", ".otf"} {
+			if fontData, err := os.ReadFile(filepath.Join(fontDir, family+ext)); err == nil {
+				data = fontData
+				break
+			}
+		}
+	}
+
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font %q: %v", family, err)
+	}
+
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72, // 72 DPI 下字号即像素大小, 与 canvas 一致
+		Hinting: font.HintingNone,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %v", err)
+	}
+
+	return face, nil
+}
+
+// parseHexColor 解析 #rgb / #rrggbb / #rrggbbaa 格式的颜色
+func parseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color: %q", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color: %q", s)
+	}
+
+	return color.NRGBA{
+		R: uint8(v >> 24),
+		G: uint8(v >> 16),
+		B: uint8(v >> 8),
+		A: uint8(v),
+	}, nil
+}
+
+// clamp01 将数值限制在 [0, 1] 区间
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestRenderTextWatermark(t *testing.T) {
+	settings := WatermarkSettings{
+		Text:              "wm",
+		FontSize:          24,
+		Opacity:           0.5,
+		Angle:             -30,
+		Color:             "#ff0000",
+		HorizontalDensity: 3,
+		VerticalDensity:   3,
+	}
+
+	img, err := renderTextWatermark(settings, 320, 180)
+	if err != nil {
+		t.Fatalf("渲染水印失败: %v", err)
+	}
+
+	// 验证输出尺寸与目标分辨率一致
+	if b := img.Bounds(); b.Dx() != 320 || b.Dy() != 180 {
+		t.Fatalf("尺寸错误: 期望 320x180, 得到 %dx%d", b.Dx(), b.Dy())
+	}
+
+	// 验证存在半透明文字像素, 且没有完全不透明的像素
+	drawn := false
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] > 0 {
+			drawn = true
+		}
+		if img.Pix[i] > 128 {
+			t.Fatalf("像素不透明度超出设置: %d", img.Pix[i])
+		}
+	}
+	if !drawn {
+		t.Error("未绘制任何水印像素")
+	}
+}
+
+func TestRenderTextWatermarkInvalid(t *testing.T) {
+	valid := WatermarkSettings{Text: "a", FontSize: 12, Color: "#000", HorizontalDensity: 1, VerticalDensity: 1}
+
+	tests := []struct {
+		name   string
+		modify func(s *WatermarkSettings)
+	}{
+		{"empty text", func(s *WatermarkSettings) { s.Text = "" }},
+		{"zero font size", func(s *WatermarkSettings) { s.FontSize = 0 }},
+		{"bad color", func(s *WatermarkSettings) { s.Color = "red" }},
+		{"zero density", func(s *WatermarkSettings) { s.VerticalDensity = 0 }},
+	}
+
+	for _, tt := range tests {
+		settings := valid
+		tt.modify(&settings)
+		if _, err := renderTextWatermark(settings, 100, 100); err == nil {
+			t.Errorf("%s: 期望返回错误", tt.name)
+		}
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	c, err := parseHexColor("#0f8")
+	if err != nil {
+		t.Fatalf("解析颜色失败: %v", err)
+	}
+	if c.R != 0x00 || c.G != 0xff || c.B != 0x88 || c.A != 0xff {
+		t.Errorf("颜色解析错误: %+v", c)
+	}
+}
@@ -2,16 +2,47 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// 可执行文件路径的来源
+const (
+	sourceConfig  = "config"  // CONSTANT.json 中的路径
+	sourceEnv     = "env"     // 环境变量
+	sourceSibling = "sibling" // 与 FFmpeg 位于同一目录 (仅 ffprobe)
+	sourceBundled = "bundled" // 程序所在目录下的 bin/
+	sourcePath    = "path"    // PATH 环境变量
 )
 
 // Config 应用程序配置结构
 type Config struct {
 	BackendPort  int    `json:"backend_port"`
 	FrontendPort int    `json:"frontend_port"`
-	FFmpegPath   string `json:"ffmpeg_path"`
+	FFmpegPath   string `json:"ffmpeg_path"`  // 可选, 覆盖自动查找; 为空或文件不存在时按环境变量、bin/、PATH 查找
+	FFprobePath  string `json:"ffprobe_path"` // 可选, 覆盖自动查找; 为空或文件不存在时按环境变量、FFmpeg 同目录、bin/、PATH 查找
 	TempPath     string `json:"temp_path"`
+
+	FFmpeg  ToolLocation `json:"-"` // FFmpeg 的查找结果
+	FFprobe ToolLocation `json:"-"` // ffprobe 的查找结果
+}
+
+// ToolLocation 外部可执行文件的查找结果
+type ToolLocation struct {
+	Path   string // 使用的路径, 未找到时为可执行文件名
+	Source string // 来源 (config, env, sibling, bundled, path), 未找到时为空
+	Reason string // 选择该路径的原因, 包括被跳过的候选路径
+}
+
+// toolCandidate 可执行文件的候选路径
+type toolCandidate struct {
+	source string // 来源
+	desc   string // 来源描述 (e.g., "FFMPEG_PATH environment variable")
+	path   string // 候选路径, 为空表示未设置
 }
 
 // AppConfig 全局配置实例
@@ -31,10 +62,92 @@ func LoadConfig() error {
 		return err
 	}
 
+	// 查找 FFmpeg 和 ffprobe, 结果记录在 AppConfig.FFmpeg/FFprobe 中
+	resolveTools(&AppConfig)
+
 	// 创建临时目录
 	if err := os.MkdirAll(AppConfig.TempPath, 0755); err != nil {
 		return err
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// resolveTools 按配置、环境变量、程序目录下的 bin/、PATH 的顺序查找 FFmpeg 和 ffprobe,
+// 并将查找到的路径写回配置
+func resolveTools(cfg *Config) {
+	bundled := ""
+	if exe, err := os.Executable(); err == nil {
+		bundled = filepath.Join(filepath.Dir(exe), "bin")
+	}
+
+	cfg.FFmpeg = findTool("ffmpeg", []toolCandidate{
+		{sourceConfig, "ffmpeg_path in CONSTANT.json", cfg.FFmpegPath},
+		{sourceEnv, "FFMPEG_PATH environment variable", os.Getenv("FFMPEG_PATH")},
+		{sourceBundled, "bundled bin/ next to the executable", bundledPath(bundled, "ffmpeg")},
+	})
+	cfg.FFmpegPath = cfg.FFmpeg.Path
+
+	// ffprobe 优先使用与 FFmpeg 同目录的版本, 保证两者版本一致
+	sibling := ""
+	if cfg.FFmpeg.Source != "" {
+		sibling = siblingTool(cfg.FFmpeg.Path, "ffprobe")
+	}
+	cfg.FFprobe = findTool("ffprobe", []toolCandidate{
+		{sourceConfig, "ffprobe_path in CONSTANT.json", cfg.FFprobePath},
+		{sourceEnv, "FFPROBE_PATH environment variable", os.Getenv("FFPROBE_PATH")},
+		{sourceSibling, "same directory as ffmpeg", sibling},
+		{sourceBundled, "bundled bin/ next to the executable", bundledPath(bundled, "ffprobe")},
+	})
+	cfg.FFprobePath = cfg.FFprobe.Path
+}
+
+// findTool 返回第一个存在的候选路径, 都不存在时在 PATH 中查找
+func findTool(name string, candidates []toolCandidate) ToolLocation {
+	var skipped []string
+	reason := func(chosen string) string {
+		return strings.Join(append(skipped, chosen), "; ")
+	}
+
+	for _, c := range candidates {
+		if c.path == "" {
+			continue
+		}
+		if info, err := os.Stat(c.path); err != nil || info.IsDir() {
+			skipped = append(skipped, fmt.Sprintf("%s %s not found", c.desc, c.path))
+			continue
+		}
+		return ToolLocation{Path: c.path, Source: c.source, Reason: reason("using " + c.desc)}
+	}
+
+	if path, err := exec.LookPath(executableName(name)); err == nil {
+		return ToolLocation{Path: path, Source: sourcePath, Reason: reason("found in PATH")}
+	}
+
+	return ToolLocation{Path: executableName(name), Reason: reason(name + " not found in PATH")}
+}
+
+// executableName 返回当前系统下的可执行文件名, Windows 下添加 .exe 后缀
+func executableName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// bundledPath 返回目录 dir 下的可执行文件路径, dir 为空时返回空
+func bundledPath(dir, name string) string {
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, executableName(name))
+}
+
+// siblingTool 根据 FFmpeg 路径推导同目录下的其他工具路径 (同扩展名, e.g., ffmpeg.exe → ffprobe.exe)
+func siblingTool(ffmpegPath, name string) string {
+	dir, base := filepath.Split(ffmpegPath)
+	if !strings.Contains(base, "ffmpeg") {
+		return ""
+	}
+	return dir + strings.Replace(base, "ffmpeg", name, 1)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindTool(t *testing.T) {
+	dir := t.TempDir()
+	configured := filepath.Join(dir, "config", executableName("ffmpeg"))
+	env := filepath.Join(dir, "env", executableName("ffmpeg"))
+	for _, path := range []string{configured, env} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := filepath.Join(dir, "missing", executableName("ffmpeg"))
+
+	// PATH 中只有 path 目录
+	pathDir := filepath.Join(dir, "path")
+	inPath := filepath.Join(pathDir, executableName("ffmpeg"))
+	if err := os.MkdirAll(pathDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(inPath, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", pathDir)
+
+	tests := []struct {
+		name       string
+		candidates []toolCandidate
+		wantPath   string
+		wantSource string
+		wantReason string
+	}{
+		{
+			"使用配置路径",
+			[]toolCandidate{{sourceConfig, "config", configured}, {sourceEnv, "env", env}},
+			configured, sourceConfig, "using config",
+		},
+		{
+			"配置路径不存在时使用环境变量",
+			[]toolCandidate{{sourceConfig, "config", missing}, {sourceEnv, "env", env}},
+			env, sourceEnv, "config " + missing + " not found; using env",
+		},
+		{
+			"目录不能作为可执行文件",
+			[]toolCandidate{{sourceConfig, "config", dir}, {sourceEnv, "env", env}},
+			env, sourceEnv, "config " + dir + " not found; using env",
+		},
+		{
+			"未设置时在 PATH 中查找",
+			[]toolCandidate{{sourceConfig, "config", ""}, {sourceEnv, "env", ""}},
+			inPath, sourcePath, "found in PATH",
+		},
+	}
+
+	for _, tt := range tests {
+		got := findTool("ffmpeg", tt.candidates)
+		if got.Path != tt.wantPath || got.Source != tt.wantSource || got.Reason != tt.wantReason {
+			t.Errorf("%s: 得到 %+v, 期望 {Path:%s Source:%s Reason:%s}", tt.name, got, tt.wantPath, tt.wantSource, tt.wantReason)
+		}
+	}
+
+	// 都找不到时返回可执行文件名, 来源为空
+	t.Setenv("PATH", filepath.Join(dir, "empty"))
+	got := findTool("ffmpeg", []toolCandidate{{sourceConfig, "config", missing}})
+	if got.Source != "" || got.Path != executableName("ffmpeg") || !strings.HasSuffix(got.Reason, "ffmpeg not found in PATH") {
+		t.Errorf("未找到: 得到 %+v", got)
+	}
+}
+
+func TestSiblingTool(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"/usr/bin/ffmpeg", "/usr/bin/ffprobe"},
+		{"../bin/ffmpeg.exe", "../bin/ffprobe.exe"},
+		{"/opt/ffmpeg-6.1/bin/ffmpeg", "/opt/ffmpeg-6.1/bin/ffprobe"},
+		{"/usr/local/bin/avconv", ""},
+	}
+
+	for _, tt := range tests {
+		if got := siblingTool(tt.input, "ffprobe"); got != tt.want {
+			t.Errorf("%s: 得到 %q, 期望 %q", tt.input, got, tt.want)
+		}
+	}
+}
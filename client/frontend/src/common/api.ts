@@ -1,4 +1,4 @@
-import { API_PATHS, APIResponse, FileInfo, ProcessRequest, TaskStatus, WatermarkRequest } from './types'
+import { API_PATHS, APIResponse, BatchRequest, BatchStatus, FFmpegCapabilities, FileInfo, MediaInfo, ProcessRequest, TaskStatus, TextWatermarkRequest, VerifyRequest, VerifyStatus, WatermarkRequest } from './types'
 
 // API 基础配置
 const API_BASE_URL = 'http://localhost:8080'
@@ -38,6 +38,20 @@ export async function getFileUrl(path: string): Promise<string> {
   return `${API_BASE_URL}${API_PATHS.GET_FILE}/${encodeURIComponent(path)}`
 }
 
+export async function probeMedia(path: string): Promise<MediaInfo> {
+  const url = new URL(API_PATHS.PROBE_MEDIA, API_BASE_URL)
+  url.searchParams.set('path', path)
+  const response = await fetch(url)
+  return handleResponse<MediaInfo>(response)
+}
+
+// FFmpeg API
+export async function getFFmpegCapabilities(): Promise<FFmpegCapabilities> {
+  const url = new URL(API_PATHS.GET_FFMPEG_CAPABILITIES, API_BASE_URL)
+  const response = await fetch(url)
+  return handleResponse<FFmpegCapabilities>(response)
+}
+
 // 水印相关 API
 export async function saveWatermark(imageData: string): Promise<string> {
   const response = await fetch(`${API_BASE_URL}${API_PATHS.SAVE_WATERMARK}`, {
@@ -51,6 +65,20 @@ export async function saveWatermark(imageData: string): Promise<string> {
   return handleResponse<string>(response)
 }
 
+export async function renderTextWatermark(request: TextWatermarkRequest): Promise<string> {
+  const response = await fetch(`${API_BASE_URL}${API_PATHS.RENDER_WATERMARK}`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify(request),
+  })
+
+  return handleResponse<string>(response)
+}
+
+// 提交处理任务, 返回任务ID; 任务进入队列后异步启动, 启动失败 (e.g., FFmpeg 无法运行) 不会使请求失败,
+// 只体现为任务状态 failed 和 error, 需要通过 getProcessStatus 查询
 export async function processMedia(request: ProcessRequest): Promise<string> {
   const response = await fetch(`${API_BASE_URL}${API_PATHS.PROCESS_MEDIA}`, {
     method: 'POST',
@@ -79,4 +107,42 @@ export async function generateCommand(request: ProcessRequest): Promise<string>
   })
 
   return handleResponse<string>(response)
+}
+
+// 批量处理 API
+export async function batchProcess(request: BatchRequest): Promise<string> {
+  const response = await fetch(`${API_BASE_URL}${API_PATHS.BATCH_PROCESS}`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify(request),
+  })
+
+  return handleResponse<string>(response)
+}
+
+export async function getBatchStatus(batchId: string): Promise<BatchStatus> {
+  const url = new URL(`${API_PATHS.GET_BATCH_STATUS}/${batchId}`, API_BASE_URL)
+  const response = await fetch(url)
+  return handleResponse<BatchStatus>(response)
+}
+
+// 水印校验 API
+export async function verifyMedia(request: VerifyRequest): Promise<string> {
+  const response = await fetch(`${API_BASE_URL}${API_PATHS.VERIFY_MEDIA}`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify(request),
+  })
+
+  return handleResponse<string>(response)
+}
+
+export async function getVerifyStatus(taskId: string): Promise<VerifyStatus> {
+  const url = new URL(`${API_PATHS.GET_VERIFY_STATUS}/${taskId}`, API_BASE_URL)
+  const response = await fetch(url)
+  return handleResponse<VerifyStatus>(response)
 }
\ No newline at end of file
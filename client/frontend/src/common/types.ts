@@ -1,3 +1,5 @@
+import type { WatermarkSettings } from './canvas'
+
 export type TabType = 'files' | 'watermark' | 'execute'
 
 export interface Step {
@@ -25,24 +27,275 @@ export interface WatermarkRequest {
   imageData: string; // Base64 编码的水印图片数据
 }
 
+// 文字水印渲染请求类型
+export interface TextWatermarkRequest {
+  sourcePath: string;          // 源文件路径, 用于获取输出分辨率
+  width: number;               // 输出宽度, 为 0 时使用源文件分辨率
+  height: number;              // 输出高度, 为 0 时使用源文件分辨率
+  settings: WatermarkSettings; // 水印设置
+}
+
+// 水印位置 (九宫格锚点)
+export type WatermarkPosition =
+  | 'top-left' | 'top' | 'top-right'
+  | 'left' | 'center' | 'right'
+  | 'bottom-left' | 'bottom' | 'bottom-right'
+
+// 水印混合模式
+export type BlendMode = 'normal' | 'multiply' | 'screen' | 'overlay' | 'softlight'
+
+// 水印层类型
+export interface WatermarkLayer {
+  watermarkPath: string;                       // 水印图片路径, 支持 GIF/APNG 动图和 WebM/MOV 等视频 (不可见水印不需要)
+  position: WatermarkPosition;                 // 水印位置, 九宫格锚点
+  offsetX?: number;                            // 相对锚点的水平偏移, 正值向右
+  offsetY?: number;                            // 相对锚点的垂直偏移, 正值向下
+  margin?: number;                             // 水印与画面边缘的距离
+  unit?: 'px' | '%';                           // 偏移量和边距的单位, 默认为 "px"
+  scale: number;                               // 水印缩放比例 (百分比), 含义由 sizeMode 决定
+  sizeMode?: 'watermark' | 'width' | 'height'; // 尺寸模式, 相对水印自身或主画面宽/高, 默认为 "watermark"
+  minSize?: number;                            // 相对主画面缩放时的最小像素尺寸
+  maxSize?: number;                            // 相对主画面缩放时的最大像素尺寸
+  opacity: number;                             // 水印不透明度 (0-100)
+  blend?: BlendMode;                           // 混合模式, 默认为 "normal"
+  mode?: 'single' | 'tile' | 'invisible';      // 水印模式, 默认为 "single"
+  tile?: TileOptions;                          // 平铺模式设置
+  motion?: MotionOptions;                      // 水印运动设置
+  visibility?: VisibilityOptions;              // 水印显示时间设置, 默认全程显示
+  playback?: 'loop' | 'once';                  // 动图/视频水印的播放方式, 默认为 "loop"
+  effects?: EffectOptions;                     // 淡入淡出和脉冲效果设置
+  rotation?: number;                           // 旋转角度 (度, 顺时针)
+  perspective?: number[];                      // 透视变换后四个角的 x,y 坐标 (水印宽高的百分比, 共 8 个值)
+  payload?: string;                            // 不可见水印的载荷, 最多 16 位十六进制数
+  strength?: number;                           // 不可见水印的嵌入强度, 默认为 12
+}
+
 // 媒体处理请求类型
-export interface ProcessRequest {
-  sourcePath: string;    // 源文件路径
-  outputPath: string;    // 输出文件路径
-  watermarkPath: string; // 水印图片路径
-  position: string;      // 水印位置 (e.g., "center", "top-left")
-  scale: number;         // 水印缩放比例 (百分比)
-  opacity: number;       // 水印透明度 (0-100)
+// 顶层的水印字段描述单个水印; 设置 layers 时忽略顶层水印字段, 按顺序叠加所有水印层
+export interface ProcessRequest extends Partial<WatermarkLayer> {
+  sourcePath: string;         // 源文件路径
+  outputPath: string;         // 输出文件路径
+  layers?: WatermarkLayer[];  // 多个水印层, 按顺序叠加
+  regions?: RemovalRegion[];  // 叠加水印之前需要去除的区域
+  encoding?: EncodingOptions; // 输出编码设置, 未设置的项使用 FFmpeg 默认值
+  image?: ImageOptions;       // 静态图片输出设置, 源文件为图片时使用
+}
+
+// 输出编码设置类型
+export interface EncodingOptions {
+  videoCodec?: string;  // 视频编码器 (e.g., "libx264", "libx265", "libvpx-vp9")
+  crf?: number;         // 恒定质量因子, 与 bitrate 二选一
+  bitrate?: string;     // 目标视频码率 (e.g., "2500k", "4M")
+  preset?: string;      // 编码预设 (e.g., "veryfast", "slow")
+  pixelFormat?: string; // 像素格式 (e.g., "yuv420p")
+  maxWidth?: number;    // 最大输出宽度, 超出时等比缩小
+  maxHeight?: number;   // 最大输出高度, 超出时等比缩小
+  audioCodec?: string;  // 音频编码器, 默认为 "copy"
+  fastStart?: boolean;  // 是否添加 -movflags +faststart, 便于网络播放
+  container?: string;   // 强制输出容器格式 (e.g., "mp4", "matroska"), 默认由扩展名决定
+}
+
+// 静态图片输出设置类型
+export interface ImageOptions {
+  jpegQuality?: number;    // JPEG 质量 (1-100)
+  pngCompression?: number; // PNG 压缩级别 (1-9)
+}
+
+// 平铺水印设置类型
+export interface TileOptions {
+  spacingX: number;  // 水平间距 (像素)
+  spacingY: number;  // 垂直间距 (像素)
+  rowOffset: number; // 相邻行的水平错位 (像素), 用于砖块排列
+  angle: number;     // 整体旋转角度 (度)
+}
+
+// 水印运动设置类型
+export interface MotionOptions {
+  type: 'none' | 'scroll' | 'bounce' | 'orbit'; // 运动类型
+  speed: number;                                // 运动速度, scroll/bounce 为像素/秒, orbit 为度/秒
+  direction?: 'left' | 'right' | 'up' | 'down'; // scroll 的滚动方向
+  radiusX?: number;                             // orbit 的水平半径 (画面宽度百分比, 0-50)
+  radiusY?: number;                             // orbit 的垂直半径 (画面高度百分比, 0-50)
+}
+
+// 时间段类型 (秒), 负数表示相对于视频结尾的时间
+export interface TimeRange {
+  start: number; // 开始时间
+  end: number;   // 结束时间, 为 0 表示直到视频结尾
+}
+
+// 水印显示时间设置类型
+export interface VisibilityOptions {
+  intervals?: TimeRange[]; // 显示的时间段, 为空表示不限制
+  every?: number;          // 周期显示: 每隔多少秒
+  duration?: number;       // 周期显示: 每次显示多少秒
+}
+
+// 水印透明度效果设置类型
+export interface EffectOptions {
+  fadeIn?: number;          // 淡入时长 (秒), 从水印第一次出现时开始
+  fadeOut?: number;         // 淡出时长 (秒), 在水印最后一次消失时结束
+  fadeOutEnd?: number;      // 淡出结束时间 (秒), 默认为最后的显示时间或视频结尾
+  pulsePeriod?: number;     // 脉冲周期 (秒)
+  pulseMinOpacity?: number; // 脉冲时的最低不透明度 (0-100, 相对于 opacity)
 }
 
 // 任务状态类型
 export interface TaskStatus {
-  id: string;        // 任务ID
-  status: string;    // 状态 (pending, processing, completed, failed)
-  progress: number;  // 进度 (0-100)
-  error: string;     // 错误信息
-  createdAt: string; // 创建时间
-  updatedAt: string; // 更新时间
+  id: string;            // 任务ID
+  status: string;        // 状态 (pending, queued, processing, completed, failed)
+  progress: number;      // 进度 (0-100)
+  queuePosition: number; // 排队位置 (从 1 开始), 不在排队时为 0
+  duration: number;      // 源文件总时长 (秒)
+  currentTime: number;   // 已处理的媒体时长 (秒)
+  frame: number;         // 已处理帧数
+  fps: number;           // 处理帧率
+  bitrate: string;       // 输出码率 (e.g., "1024.0kbits/s")
+  speed: number;         // 处理速度 (相对实时的倍数)
+  outTime: string;       // 已输出时长 (HH:MM:SS.micro)
+  totalSize: number;     // 已输出文件大小 (字节)
+  eta: number;           // 预计剩余时间 (秒)
+  error: string;         // 错误信息, 包括排队后启动失败的原因
+  createdAt: string;     // 创建时间
+  updatedAt: string;     // 更新时间
+}
+
+// 需要去除的画面区域类型, 坐标和尺寸为源画面像素
+export interface RemovalRegion {
+  x: number;                               // 区域左上角横坐标
+  y: number;                               // 区域左上角纵坐标
+  width: number;                           // 区域宽度
+  height: number;                          // 区域高度
+  method?: 'delogo' | 'blur' | 'pixelate'; // 去除方式, 默认为 "delogo"
+  strength?: number;                       // 模糊半径或马赛克块大小 (像素)
+  start?: number;                          // 开始时间 (秒)
+  end?: number;                            // 结束时间 (秒), 0 表示到结尾
+}
+
+// 批量处理的接收者类型
+export interface Recipient {
+  id?: string;    // 接收者 ID
+  name?: string;  // 接收者名称
+  email?: string; // 接收者邮箱
+}
+
+// 批量处理请求类型
+// outputTemplate 和 text.text 中可以使用 {id}、{name}、{email}、{index} 占位符
+export interface BatchRequest {
+  sourcePath: string;         // 源文件路径
+  outputTemplate: string;     // 输出路径模板 (e.g., "screeners/{index}_{name}.mp4")
+  manifestPath?: string;      // 清单文件路径, 默认为输出目录下的 manifest.json
+  recipients?: Recipient[];   // 接收者列表
+  recipientsCsv?: string;     // CSV 格式的接收者列表, 表头包含 id、name、email 列
+  text: WatermarkSettings;    // 接收者文字水印设置
+  layers?: WatermarkLayer[];  // 叠加在所有输出上的其他水印层
+  regions?: RemovalRegion[];  // 叠加水印之前需要去除的区域
+  encoding?: EncodingOptions; // 输出编码设置
+  image?: ImageOptions;       // 静态图片输出设置
+}
+
+// 单个接收者的处理结果类型
+export interface BatchItem {
+  index: number;        // 接收者序号 (从 1 开始)
+  recipient: Recipient; // 接收者信息
+  outputPath: string;   // 输出文件路径
+  taskId: string;       // 对应的处理任务ID
+  status: string;       // 状态 (pending, processing, completed, failed)
+  error: string;        // 错误信息
+  size: number;         // 输出文件大小 (字节)
+  sha256: string;       // 输出文件的 SHA-256
+}
+
+// 批量任务状态类型
+export interface BatchStatus {
+  id: string;           // 批量任务ID
+  status: string;       // 状态 (pending, processing, completed, failed)
+  total: number;        // 接收者总数
+  completed: number;    // 已完成的输出数
+  failed: number;       // 失败的输出数
+  manifestPath: string; // 清单文件路径
+  items: BatchItem[];   // 每个接收者的处理结果
+  error: string;        // 错误信息
+  createdAt: string;    // 创建时间
+  updatedAt: string;    // 更新时间
+}
+
+// 水印校验请求类型
+export interface VerifyRequest {
+  sourcePath: string;    // 待校验的媒体文件路径
+  watermarkPath: string; // 水印图片路径, 必须位于 watermarks 目录中
+  interval?: number;     // 采样间隔 (秒), 默认为 1
+  threshold?: number;    // 判定为匹配的最低置信度 (0-1), 默认为 0.7
+  scales?: number[];     // 水印在画面中相对原图的缩放比例 (百分比), 默认为 [100]
+}
+
+// 单帧的水印匹配结果, 位置和尺寸为源画面像素
+export interface FrameMatch {
+  time: number;       // 采样时间 (秒)
+  x: number;          // 水印左上角横坐标
+  y: number;          // 水印左上角纵坐标
+  width: number;      // 水印宽度
+  height: number;     // 水印高度
+  scale: number;      // 匹配的缩放比例 (百分比)
+  confidence: number; // 置信度 (0-1)
+  matched: boolean;   // 置信度是否达到阈值
+}
+
+// 水印校验任务状态类型
+export interface VerifyStatus extends TaskStatus {
+  detected: boolean;     // 是否有任一采样帧匹配到水印
+  matches: FrameMatch[]; // 每个采样帧的最佳匹配结果
+}
+
+// 媒体文件探测结果类型
+export interface MediaInfo {
+  path: string;              // 文件路径
+  format: string;            // 容器格式 (e.g., "mov,mp4,m4a,3gp,3g2,mj2")
+  duration: number;          // 时长 (秒), 静态图片为 0
+  bitRate: number;           // 总码率 (bit/s)
+  size: number;              // 文件大小 (字节)
+  video: VideoStream | null; // 第一个视频流, 没有视频流时为 null
+  audio: AudioStream[];      // 音频轨道
+}
+
+// 视频流信息类型
+export interface VideoStream {
+  index: number;         // 流序号
+  codec: string;         // 编码器名称 (e.g., "h264")
+  width: number;         // 编码宽度
+  height: number;        // 编码高度
+  displayWidth: number;  // 旋转后的显示宽度
+  displayHeight: number; // 旋转后的显示高度
+  frameRate: string;     // 帧率 (e.g., "30000/1001")
+  fps: number;           // 帧率数值
+  pixelFormat: string;   // 像素格式 (e.g., "yuv420p")
+  rotation: number;      // 顺时针旋转角度 (0, 90, 180, 270)
+  bitRate: number;       // 码率 (bit/s)
+}
+
+// 音频轨道信息类型
+export interface AudioStream {
+  index: number;         // 流序号
+  codec: string;         // 编码器名称 (e.g., "aac")
+  sampleRate: number;    // 采样率 (Hz)
+  channels: number;      // 声道数
+  channelLayout: string; // 声道布局 (e.g., "stereo")
+  language: string;      // 语言标签
+  bitRate: number;       // 码率 (bit/s)
+}
+
+// FFmpeg 版本及能力类型
+export interface FFmpegCapabilities {
+  path: string;          // FFmpeg 可执行文件路径
+  source: string;        // 路径来源 (config, env, bundled, path)
+  reason: string;        // 选择该路径的原因
+  version: string;       // 版本号 (e.g., "6.1.1")
+  configuration: string; // 编译配置
+  encoders: string[];    // 编码器名称 (e.g., "libx264")
+  codecs: string[];      // 可编码的编解码器名称 (e.g., "h264")
+  decoders: string[];    // 解码器名称 (e.g., "libvpx-vp9")
+  filters: string[];     // 滤镜名称
+  hwaccels: string[];    // 硬件加速方式 (e.g., "cuda")
 }
 
 // API 响应类型
@@ -59,10 +312,23 @@ export const API_PATHS = {
   UPLOAD_FILE: '/api/files',
   GET_FILE: '/api/files',
   PREVIEW_MEDIA: '/api/preview',
+  PROBE_MEDIA: '/api/probe',
+
+  // FFmpeg 相关路由
+  GET_FFMPEG_CAPABILITIES: '/api/ffmpeg',
 
   // 水印相关路由
   SAVE_WATERMARK: '/api/watermark',
+  RENDER_WATERMARK: '/api/watermark/text',
   PROCESS_MEDIA: '/api/process',
   GET_PROCESS_STATUS: '/api/process',
   GENERATE_COMMAND: '/api/generate-command',
+
+  // 批量处理相关路由
+  BATCH_PROCESS: '/api/batch',
+  GET_BATCH_STATUS: '/api/batch',
+
+  // 水印校验相关路由
+  VERIFY_MEDIA: '/api/verify',
+  GET_VERIFY_STATUS: '/api/verify',
 } as const;
\ No newline at end of file
@@ -1,6 +1,7 @@
 import { useState } from 'react'
 import { processMedia, getProcessStatus } from '../../common/api'
 import { FileList } from '../FileList'
+import type { ProcessRequest } from '../../common/types'
 
 interface ExecuteStepProps {
   selectedFiles: File[]
@@ -75,7 +76,7 @@ export function ExecuteStep({
             disabled={!watermarkImage || selectedFiles.length === 0 || !outputDir || !!taskId}
             onClick={async () => {
               try {
-                const request = {
+                const request: ProcessRequest = {
                   sourcePath: selectedFiles[0].name,
                   outputPath: outputDir,
                   watermarkPath: watermarkImage!,
@@ -99,7 +100,8 @@ export function ExecuteStep({
                       clearInterval(interval)
                     }
 
-                    if (taskStatus.status === 'completed') {
+                    // 任务排队后才启动, 启动失败或被停止时状态为 failed
+                    if (taskStatus.status === 'completed' || taskStatus.status === 'failed') {
                       clearInterval(interval)
                     }
                   } catch (err) {
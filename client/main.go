@@ -4,7 +4,6 @@ import (
 	"context"
 	"embed"
 	"fmt"
-	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -40,11 +39,11 @@ func main() {
 				fmt.Println("配置文件加载成功！")
 			}
 
-			if _, err := os.Stat(AppConfig.FFmpegPath); os.IsNotExist(err) {
-				runtime.EventsEmit(ctx, "error", "未找到 FFmpeg 可执行文件，请确保已正确安装 FFmpeg.")
-				fmt.Println("未找到 FFmpeg 可执行文件，请确保已正确安装 FFmpeg.")
+			if AppConfig.FFmpeg.Source == "" {
+				runtime.EventsEmit(ctx, "error", "未找到 FFmpeg 可执行文件，请确保已正确安装 FFmpeg.（"+AppConfig.FFmpeg.Reason+"）")
+				fmt.Println("未找到 FFmpeg 可执行文件，请确保已正确安装 FFmpeg.（" + AppConfig.FFmpeg.Reason + "）")
 			} else {
-				fmt.Println("FFmpeg 可执行文件路径：" + AppConfig.FFmpegPath)
+				fmt.Println("FFmpeg 可执行文件路径：" + AppConfig.FFmpegPath + "（" + AppConfig.FFmpeg.Reason + "）")
 			}
 		},
 		Bind: []interface{}{